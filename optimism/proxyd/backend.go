@@ -4,13 +4,16 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"math"
 	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
@@ -83,10 +86,53 @@ var (
 		Message:       "sender is over rate limit",
 		HTTPErrorCode: 429,
 	}
+	ErrNoConsensus = &RPCErr{
+		Code:          JSONRPCErrorInternal - 18,
+		Message:       "no backends in consensus",
+		HTTPErrorCode: 503,
+	}
 
 	ErrBackendUnexpectedJSONRPC = errors.New("backend returned an unexpected JSON-RPC response")
 )
 
+// NoConsensusError is returned by a backend group rejecting requests for its consensus. The clients are answered
+// with ErrNoConsensus, and the error wraps the not-ready condition of the consensus, i.e. ErrConsensusSplitBrain,
+// so errors.Is matches both ErrNoConsensus and ErrConsensusNotReady
+type NoConsensusError struct {
+	Reason error
+}
+
+func (e *NoConsensusError) Error() string {
+	return fmt.Sprintf("%s: %v", ErrNoConsensus.Message, e.Reason)
+}
+
+func (e *NoConsensusError) Unwrap() error {
+	return e.Reason
+}
+
+// Is matches ErrNoConsensus, the error the clients are answered with
+func (e *NoConsensusError) Is(target error) bool {
+	return target == ErrNoConsensus
+}
+
+// As finds ErrNoConsensus as the RPC error the clients are answered with
+func (e *NoConsensusError) As(target interface{}) bool {
+	rpcErr, ok := target.(**RPCErr)
+	if ok {
+		*rpcErr = ErrNoConsensus
+	}
+	return ok
+}
+
+// BackendHTTPStatusError is returned when a backend responds with an unexpected HTTP status code
+type BackendHTTPStatusError struct {
+	StatusCode int
+}
+
+func (e *BackendHTTPStatusError) Error() string {
+	return fmt.Sprintf("response code %d", e.StatusCode)
+}
+
 func ErrInvalidRequest(msg string) *RPCErr {
 	return &RPCErr{
 		Code:          -32600,
@@ -104,8 +150,12 @@ func ErrInvalidParams(msg string) *RPCErr {
 }
 
 type Backend struct {
-	Name                 string
-	rpcURL               string
+	Name   string
+	rpcURL string
+	// rpcURLGeneration is bumped every time the rpc URL is swapped, see BackendGroup.UpdateBackendURL
+	rpcURLGeneration uint64
+	rpcURLMtx        sync.RWMutex
+
 	wsURL                string
 	authUsername         string
 	authPassword         string
@@ -119,6 +169,42 @@ type Backend struct {
 	outOfServiceInterval time.Duration
 	stripTrailingXFF     bool
 	proxydIP             string
+	weight               int
+	consensusVoting      bool
+	// consensusBlockMethod overrides the method polled for the blocks, i.e. for a vendor namespacing it behind a gateway
+	consensusBlockMethod string
+	// consensusTagMapping maps a block tag polled by the consensus to the tag the backend serves the same head under
+	consensusTagMapping map[string]string
+	// syncStatusURL is the rollup node the consensus poller reads the sync status of the backend from
+	syncStatusURL string
+	// consensusMaxLatency is the latency of a poll over which the backend is slow, zero never flags it
+	consensusMaxLatency time.Duration
+	// local backends are preferred over the remote ones among the equally up-to-date consensus members
+	local bool
+	// socketPath is set when the rpc URL is a unix:// URL, the requests are then sent to the IPC socket of the node
+	socketPath string
+
+	// draining backends are excluded from routing and consensus, see BackendGroup.DrainBackend
+	draining    bool
+	drainingMtx sync.RWMutex
+
+	// rpcWSConns are the connections used to forward requests when the rpc URL is a websocket URL, each carrying
+	// one request at a time. The idle ones are reused, up to maxIdleRPCWSConns
+	rpcWSConns     map[*rpcWSConn]struct{}
+	rpcWSIdleConns []*rpcWSConn
+	rpcWSShutdown  bool
+	rpcWSConnMtx   sync.Mutex
+}
+
+// maxIdleRPCWSConns is the number of idle websocket connections kept open to a backend
+const maxIdleRPCWSConns = 8
+
+// rpcWSConn is a websocket connection to the rpc URL of a backend, dialed with the X-Forwarded-For header of the
+// request that opened it, so it only carries the requests forwarded for the same clients
+type rpcWSConn struct {
+	*websocket.Conn
+	xForwardedFor string
+	generation    uint64
 }
 
 type BackendOpt func(b *Backend)
@@ -187,6 +273,80 @@ func WithProxydIP(ip string) BackendOpt {
 	}
 }
 
+// WithWeight sets the weight of the backend in weighted consensus modes
+func WithWeight(weight int) BackendOpt {
+	return func(b *Backend) {
+		b.weight = weight
+	}
+}
+
+// WithConsensusVoting sets whether the backend takes part in defining the consensus. A non-voting
+// backend is never polled for its head, but remains available to serve requests
+func WithConsensusVoting(voting bool) BackendOpt {
+	return func(b *Backend) {
+		b.consensusVoting = voting
+	}
+}
+
+// WithConsensusBlockMethod overrides the method the consensus poller fetches the blocks with, for backends
+// serving eth_getBlockByNumber under another name. The method takes the same params and returns the same block
+func WithConsensusBlockMethod(method string) BackendOpt {
+	return func(b *Backend) {
+		b.consensusBlockMethod = method
+	}
+}
+
+// WithConsensusTagMapping maps the block tags the consensus poller fetches to the tags the backend serves the
+// same heads under, for backends with other tag semantics than the rest of the group, i.e. "latest" to "safe"
+// for a backend whose latest block is the unsafe head while the others serve the safe head as latest
+func WithConsensusTagMapping(mapping map[string]string) BackendOpt {
+	return func(b *Backend) {
+		b.consensusTagMapping = mapping
+	}
+}
+
+// WithSyncStatusURL sets the rollup node the consensus poller reads the sync status of the backend from, when
+// it polls the heads with optimism_syncStatus. The blocks validating the consensus are still fetched from the
+// rpc URL of the backend, its execution client, as a rollup node doesn't serve eth_getBlockByNumber
+func WithSyncStatusURL(syncStatusURL string) BackendOpt {
+	return func(b *Backend) {
+		b.syncStatusURL = syncStatusURL
+	}
+}
+
+// consensusBlockTag returns the tag or block number the consensus poller fetches the given block with
+func (b *Backend) consensusBlockTag(block string) string {
+	if tag, ok := b.consensusTagMapping[block]; ok {
+		return tag
+	}
+	return block
+}
+
+// isBlockTag returns true for the named blocks of the block parameter of the JSON-RPC API
+func isBlockTag(tag string) bool {
+	switch tag {
+	case "earliest", "latest", "pending", "safe", "finalized":
+		return true
+	}
+	return false
+}
+
+// WithConsensusMaxLatency excludes the backend from voting in the consensus, without banning it, while it takes
+// longer than maxLatency to answer its polls, so a slow backend doesn't hold back the poll cycles
+func WithConsensusMaxLatency(maxLatency time.Duration) BackendOpt {
+	return func(b *Backend) {
+		b.consensusMaxLatency = maxLatency
+	}
+}
+
+// WithLocal tags the backend as local, i.e. low latency and trusted. Among the consensus members that
+// are equally up to date, requests are routed to the local backends ahead of the remote ones
+func WithLocal(local bool) BackendOpt {
+	return func(b *Backend) {
+		b.local = local
+	}
+}
+
 func NewBackend(
 	name string,
 	rpcURL string,
@@ -206,13 +366,18 @@ func NewBackend(
 			sem:         rpcSemaphore,
 			backendName: name,
 		},
-		dialer: &websocket.Dialer{},
+		dialer:          &websocket.Dialer{},
+		consensusVoting: true,
 	}
 
 	for _, opt := range opts {
 		opt(backend)
 	}
 
+	if isUnixSocketURL(rpcURL) {
+		backend.useUnixSocket(strings.TrimPrefix(rpcURL, "unix://"))
+	}
+
 	if !backend.stripTrailingXFF && backend.proxydIP == "" {
 		log.Warn("proxied requests' XFF header will not contain the proxyd ip address")
 	}
@@ -318,6 +483,24 @@ func (b *Backend) Online() bool {
 	return online
 }
 
+// IsDraining returns true if the backend is being drained ahead of maintenance
+func (b *Backend) IsDraining() bool {
+	b.drainingMtx.RLock()
+	defer b.drainingMtx.RUnlock()
+	return b.draining
+}
+
+func (b *Backend) setDraining(draining bool) {
+	b.drainingMtx.Lock()
+	b.draining = draining
+	b.drainingMtx.Unlock()
+}
+
+// votesInConsensus returns true if the backend takes part in defining the consensus
+func (b *Backend) votesInConsensus() bool {
+	return b.consensusVoting && !b.IsDraining()
+}
+
 func (b *Backend) IsRateLimited() bool {
 	if b.maxRPS == 0 {
 		return false
@@ -367,6 +550,17 @@ func (b *Backend) setOffline() {
 
 // ForwardRPC makes a call directly to a backend and populate the response into `res`
 func (b *Backend) ForwardRPC(ctx context.Context, res *RPCRes, id string, method string, params ...any) error {
+	return b.forwardRPC(ctx, b.client, res, id, method, params...)
+}
+
+// forwardRPC is like ForwardRPC, but sends the request through the given client
+func (b *Backend) forwardRPC(ctx context.Context, client *LimitedHTTPClient, res *RPCRes, id string, method string, params ...any) error {
+	return b.forwardRPCToURL(ctx, client, "", res, id, method, params...)
+}
+
+// forwardRPCToURL is like forwardRPC, but sends the request to another HTTP URL than the rpc URL of the
+// backend when rpcURL is set, i.e. to its rollup node
+func (b *Backend) forwardRPCToURL(ctx context.Context, client *LimitedHTTPClient, rpcURL string, res *RPCRes, id string, method string, params ...any) error {
 	jsonParams, err := json.Marshal(params)
 	if err != nil {
 		return err
@@ -379,7 +573,12 @@ func (b *Backend) ForwardRPC(ctx context.Context, res *RPCRes, id string, method
 		ID:      []byte(id),
 	}
 
-	slicedRes, err := b.doForward(ctx, []*RPCReq{&rpcReq}, false)
+	var slicedRes []*RPCRes
+	if rpcURL == "" {
+		slicedRes, err = b.doForwardWithClient(ctx, client, []*RPCReq{&rpcReq}, false)
+	} else {
+		slicedRes, err = b.doForwardHTTP(ctx, client, rpcURL, []*RPCReq{&rpcReq}, false)
+	}
 	if err != nil {
 		return err
 	}
@@ -396,6 +595,19 @@ func (b *Backend) ForwardRPC(ctx context.Context, res *RPCRes, id string, method
 }
 
 func (b *Backend) doForward(ctx context.Context, rpcReqs []*RPCReq, isBatch bool) ([]*RPCRes, error) {
+	return b.doForwardWithClient(ctx, b.client, rpcReqs, isBatch)
+}
+
+func (b *Backend) doForwardWithClient(ctx context.Context, client *LimitedHTTPClient, rpcReqs []*RPCReq, isBatch bool) ([]*RPCRes, error) {
+	// the URL is read once, so a request in flight while the URL is swapped completes against the previous one
+	rpcURL, _ := b.getRPCURL()
+	if isWebsocketURL(rpcURL) {
+		return b.doForwardWS(ctx, client, rpcReqs, isBatch)
+	}
+	return b.doForwardHTTP(ctx, client, rpcURL, rpcReqs, isBatch)
+}
+
+func (b *Backend) doForwardHTTP(ctx context.Context, client *LimitedHTTPClient, rpcURL string, rpcReqs []*RPCReq, isBatch bool) ([]*RPCRes, error) {
 	isSingleElementBatch := len(rpcReqs) == 1
 
 	// Single element batches are unwrapped before being sent
@@ -408,30 +620,65 @@ func (b *Backend) doForward(ctx context.Context, rpcReqs []*RPCReq, isBatch bool
 		body = mustMarshalJSON(rpcReqs)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", b.rpcURL, bytes.NewReader(body))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", rpcURL, bytes.NewReader(body))
 	if err != nil {
-		return nil, wrapErr(err, "error creating backend request")
+		return nil, wrapErr(redactURLError(err), "error creating backend request")
 	}
 
 	if b.authPassword != "" {
 		httpReq.SetBasicAuth(b.authUsername, b.authPassword)
 	}
 
+	httpReq.Header.Set("content-type", "application/json")
+	httpReq.Header.Set("X-Forwarded-For", b.forwardedFor(ctx))
+
+	httpRes, err := client.DoLimited(httpReq)
+	if err != nil {
+		return nil, wrapErr(redactURLError(err), "error in backend request")
+	}
+	defer httpRes.Body.Close()
+
+	b.recordResponseCode(ctx, rpcReqs, isBatch, httpRes.StatusCode)
+
+	// Alchemy returns a 400 on bad JSONs, so handle that case
+	if httpRes.StatusCode != 200 && httpRes.StatusCode != 400 {
+		return nil, &BackendHTTPStatusError{StatusCode: httpRes.StatusCode}
+	}
+
+	resB, err := io.ReadAll(io.LimitReader(httpRes.Body, b.maxResponseSize))
+	if err != nil {
+		return nil, wrapErr(err, "error reading response body")
+	}
+
+	res, err := parseBackendResponse(rpcReqs, resB)
+	if err != nil {
+		return nil, err
+	}
+
+	// capture the HTTP status code in the response. this will only
+	// ever be 400 given the status check on line 318 above.
+	if httpRes.StatusCode != 200 {
+		for _, res := range res {
+			res.Error.HTTPErrorCode = httpRes.StatusCode
+		}
+	}
+
+	return res, nil
+}
+
+// forwardedFor returns the X-Forwarded-For header sent to the backend with the requests of the context
+func (b *Backend) forwardedFor(ctx context.Context) string {
 	xForwardedFor := GetXForwardedFor(ctx)
 	if b.stripTrailingXFF {
 		xForwardedFor = stripXFF(xForwardedFor)
 	} else if b.proxydIP != "" {
 		xForwardedFor = fmt.Sprintf("%s, %s", xForwardedFor, b.proxydIP)
 	}
+	return xForwardedFor
+}
 
-	httpReq.Header.Set("content-type", "application/json")
-	httpReq.Header.Set("X-Forwarded-For", xForwardedFor)
-
-	httpRes, err := b.client.DoLimited(httpReq)
-	if err != nil {
-		return nil, wrapErr(err, "error in backend request")
-	}
-
+// recordResponseCode counts a response of the backend by its HTTP status code
+func (b *Backend) recordResponseCode(ctx context.Context, rpcReqs []*RPCReq, isBatch bool, statusCode int) {
 	metricLabelMethod := rpcReqs[0].Method
 	if isBatch {
 		metricLabelMethod = "<batch>"
@@ -440,23 +687,200 @@ func (b *Backend) doForward(ctx context.Context, rpcReqs []*RPCReq, isBatch bool
 		GetAuthCtx(ctx),
 		b.Name,
 		metricLabelMethod,
-		strconv.Itoa(httpRes.StatusCode),
+		strconv.Itoa(statusCode),
 		strconv.FormatBool(isBatch),
 	).Inc()
+}
 
-	// Alchemy returns a 400 on bad JSONs, so handle that case
-	if httpRes.StatusCode != 200 && httpRes.StatusCode != 400 {
-		return nil, fmt.Errorf("response code %d", httpRes.StatusCode)
+// doForwardWS forwards the requests over a websocket connection to a backend whose rpc URL is a websocket URL.
+// Concurrent requests are sent on separate connections, and a connection is dropped after an error.
+// A response is counted as a 200 of the HTTP path
+func (b *Backend) doForwardWS(ctx context.Context, client *LimitedHTTPClient, rpcReqs []*RPCReq, isBatch bool) ([]*RPCRes, error) {
+	var body []byte
+	if len(rpcReqs) == 1 {
+		body = mustMarshalJSON(rpcReqs[0])
+	} else {
+		body = mustMarshalJSON(rpcReqs)
 	}
 
-	defer httpRes.Body.Close()
-	resB, err := io.ReadAll(io.LimitReader(httpRes.Body, b.maxResponseSize))
+	if err := client.sem.Acquire(ctx, 1); err != nil {
+		tooManyRequestErrorsTotal.WithLabelValues(b.Name).Inc()
+		return nil, wrapErr(err, "too many requests")
+	}
+	defer client.sem.Release(1)
+
+	conn, err := b.takeRPCWSConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// a zero deadline, i.e. no timeout and no context deadline, never expires
+	deadline, ok := ctx.Deadline()
+	if client.Timeout != 0 && (!ok || time.Now().Add(client.Timeout).Before(deadline)) {
+		deadline = time.Now().Add(client.Timeout)
+	}
+	_ = conn.SetWriteDeadline(deadline)
+	_ = conn.SetReadDeadline(deadline)
+
+	if err := conn.WriteMessage(websocket.TextMessage, body); err != nil {
+		b.closeRPCWSConn(conn)
+		return nil, wrapErr(err, "error in backend request")
+	}
+	_, resB, err := conn.ReadMessage()
 	if err != nil {
+		b.closeRPCWSConn(conn)
 		return nil, wrapErr(err, "error reading response body")
 	}
 
+	res, err := parseBackendResponse(rpcReqs, resB)
+	if err == nil && !responsesMatchIDs(rpcReqs, res) {
+		err = ErrBackendUnexpectedJSONRPC
+	}
+	if err != nil {
+		// the connection is out of step with its requests
+		b.closeRPCWSConn(conn)
+		return nil, err
+	}
+	b.releaseRPCWSConn(conn)
+	b.recordResponseCode(ctx, rpcReqs, isBatch, http.StatusOK)
+	return res, nil
+}
+
+// responsesMatchIDs returns true if each response answers the request at the same position, as sorted by
+// parseBackendResponse
+func responsesMatchIDs(rpcReqs []*RPCReq, res []*RPCRes) bool {
+	for i, req := range rpcReqs {
+		if !bytes.Equal(bytes.TrimSpace(req.ID), bytes.TrimSpace(res[i].ID)) {
+			return false
+		}
+	}
+	return true
+}
+
+// takeRPCWSConn returns an idle websocket connection carrying the same X-Forwarded-For header as the request,
+// or dials a new one
+func (b *Backend) takeRPCWSConn(ctx context.Context) (*rpcWSConn, error) {
+	xForwardedFor := b.forwardedFor(ctx)
+	rpcURL, generation := b.getRPCURL()
+
+	b.rpcWSConnMtx.Lock()
+	if b.rpcWSShutdown {
+		b.rpcWSConnMtx.Unlock()
+		return nil, ErrBackendOffline
+	}
+	// the most recently used connection first, the oldest ones are closed over maxIdleRPCWSConns
+	for i := len(b.rpcWSIdleConns) - 1; i >= 0; i-- {
+		conn := b.rpcWSIdleConns[i]
+		if conn.xForwardedFor == xForwardedFor && conn.generation == generation {
+			b.rpcWSIdleConns = append(b.rpcWSIdleConns[:i], b.rpcWSIdleConns[i+1:]...)
+			b.rpcWSConnMtx.Unlock()
+			return conn, nil
+		}
+	}
+	b.rpcWSConnMtx.Unlock()
+
+	header := make(http.Header)
+	if b.authPassword != "" {
+		header.Set("Authorization", "Basic "+basicAuth(b.authUsername, b.authPassword))
+	}
+	header.Set("X-Forwarded-For", xForwardedFor)
+	wsConn, _, err := b.dialer.DialContext(ctx, rpcURL, header) // nolint:bodyclose
+	if err != nil {
+		return nil, wrapErr(redactURLError(err), "error dialing backend")
+	}
+	wsConn.SetReadLimit(b.maxResponseSize)
+	conn := &rpcWSConn{Conn: wsConn, xForwardedFor: xForwardedFor, generation: generation}
+
+	b.rpcWSConnMtx.Lock()
+	defer b.rpcWSConnMtx.Unlock()
+	if b.rpcWSShutdown {
+		_ = conn.Close()
+		return nil, ErrBackendOffline
+	}
+	if b.rpcWSConns == nil {
+		b.rpcWSConns = make(map[*rpcWSConn]struct{})
+	}
+	b.rpcWSConns[conn] = struct{}{}
+	return conn, nil
+}
+
+// releaseRPCWSConn returns a connection to the idle ones after its request completed, closing the oldest idle
+// connection over maxIdleRPCWSConns, and the connections to a swapped out URL
+func (b *Backend) releaseRPCWSConn(conn *rpcWSConn) {
+	_, generation := b.getRPCURL()
+	b.rpcWSConnMtx.Lock()
+	defer b.rpcWSConnMtx.Unlock()
+	if _, open := b.rpcWSConns[conn]; !open {
+		return
+	}
+	if b.rpcWSShutdown || conn.generation != generation {
+		b.closeRPCWSConnLocked(conn)
+		return
+	}
+	if len(b.rpcWSIdleConns) >= maxIdleRPCWSConns {
+		b.closeRPCWSConnLocked(b.rpcWSIdleConns[0])
+	}
+	b.rpcWSIdleConns = append(b.rpcWSIdleConns, conn)
+}
+
+func (b *Backend) closeRPCWSConn(conn *rpcWSConn) {
+	b.rpcWSConnMtx.Lock()
+	defer b.rpcWSConnMtx.Unlock()
+	b.closeRPCWSConnLocked(conn)
+}
+
+func (b *Backend) closeRPCWSConnLocked(conn *rpcWSConn) {
+	_ = conn.Close()
+	delete(b.rpcWSConns, conn)
+	for i, idle := range b.rpcWSIdleConns {
+		if idle == conn {
+			b.rpcWSIdleConns = append(b.rpcWSIdleConns[:i], b.rpcWSIdleConns[i+1:]...)
+			break
+		}
+	}
+}
+
+// closeIdleRPCWSConns closes the idle websocket connections, the ones in flight are closed once released
+func (b *Backend) closeIdleRPCWSConns() {
+	b.rpcWSConnMtx.Lock()
+	defer b.rpcWSConnMtx.Unlock()
+	for len(b.rpcWSIdleConns) > 0 {
+		b.closeRPCWSConnLocked(b.rpcWSIdleConns[0])
+	}
+}
+
+// Shutdown closes the websocket connections of the backend, failing the requests in flight on them
+func (b *Backend) Shutdown() {
+	b.rpcWSConnMtx.Lock()
+	defer b.rpcWSConnMtx.Unlock()
+	b.rpcWSShutdown = true
+	for conn := range b.rpcWSConns {
+		b.closeRPCWSConnLocked(conn)
+	}
+}
+
+// getRPCURL returns the rpc URL of the backend, and its generation
+func (b *Backend) getRPCURL() (string, uint64) {
+	b.rpcURLMtx.RLock()
+	defer b.rpcURLMtx.RUnlock()
+	return b.rpcURL, b.rpcURLGeneration
+}
+
+// setRPCURL swaps the rpc URL of the backend. The websocket connections to the previous URL, if any, are
+// closed once their in-flight request completes, and the next request dials the new URL
+func (b *Backend) setRPCURL(rpcURL string) {
+	b.rpcURLMtx.Lock()
+	b.rpcURL = rpcURL
+	b.rpcURLGeneration++
+	b.rpcURLMtx.Unlock()
+
+	b.closeIdleRPCWSConns()
+}
+
+// parseBackendResponse parses the response body to the requests, unwrapping single element batches
+func parseBackendResponse(rpcReqs []*RPCReq, resB []byte) ([]*RPCRes, error) {
 	var res []*RPCRes
-	if isSingleElementBatch {
+	if len(rpcReqs) == 1 {
 		var singleRes RPCRes
 		if err := json.Unmarshal(resB, &singleRes); err != nil {
 			return nil, ErrBackendBadResponse
@@ -478,16 +902,114 @@ func (b *Backend) doForward(ctx context.Context, rpcReqs []*RPCReq, isBatch bool
 		return nil, ErrBackendUnexpectedJSONRPC
 	}
 
-	// capture the HTTP status code in the response. this will only
-	// ever be 400 given the status check on line 318 above.
-	if httpRes.StatusCode != 200 {
-		for _, res := range res {
-			res.Error.HTTPErrorCode = httpRes.StatusCode
+	sortBatchRPCResponse(rpcReqs, res)
+	return res, nil
+}
+
+func isWebsocketURL(url string) bool {
+	return strings.HasPrefix(url, "ws://") || strings.HasPrefix(url, "wss://")
+}
+
+func isHTTPURL(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+func isUnixSocketURL(url string) bool {
+	return strings.HasPrefix(url, "unix://")
+}
+
+// redactURL returns the URL with its user info, which some providers use to carry credentials,
+// replaced by a placeholder, so it can be logged
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		// the parse error would echo the raw URL
+		return "<invalid url>"
+	}
+	if u.User != nil {
+		u.User = url.User("xxxxx")
+	}
+	return u.String()
+}
+
+// redactURLError redacts the URL carried by a *url.Error. The HTTP client already masks the password
+// in its errors, but not the username, which is the credential of some providers
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = redactURL(urlErr.URL)
+	}
+	return err
+}
+
+// useUnixSocket makes the backend client send every request to the IPC socket at socketPath,
+// the request URL is then only a placeholder
+func (b *Backend) useUnixSocket(socketPath string) {
+	b.socketPath = socketPath
+	b.rpcURL = "http://unix"
+	b.client.Transport = &ipcTransport{socketPath: socketPath}
+}
+
+// ipcTransport sends the requests to the IPC socket of a colocated node, i.e. the geth.ipc of geth or reth,
+// which speaks plain JSON-RPC, a stream of JSON values without HTTP framing. Each request dials the socket,
+// writes the JSON-RPC request and reads back the single JSON value answering it, served as a 200 response
+type ipcTransport struct {
+	socketPath string
+	dialer     net.Dialer
+}
+
+func (t *ipcTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	ctx := req.Context()
+	conn, err := t.dialer.DialContext(ctx, "unix", t.socketPath)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	// the reads and writes blocked on the socket are released by closing it once the request is canceled
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
 		}
+	}()
+
+	if _, err := conn.Write(body); err != nil {
+		return nil, wrapErr(ctxErr(ctx, err), "error writing to the IPC socket")
+	}
+	var resBody json.RawMessage
+	if err := json.NewDecoder(conn).Decode(&resBody); err != nil {
+		return nil, wrapErr(ctxErr(ctx, err), "error reading from the IPC socket")
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(resBody)),
+		ContentLength: int64(len(resBody)),
+		Request:       req,
+	}, nil
+}
+
+// ctxErr returns the error of the context once it is done, the cause of a failed read or write of the socket
+func ctxErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
 	}
+	return err
+}
 
-	sortBatchRPCResponse(rpcReqs, res)
-	return res, nil
+func basicAuth(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
 }
 
 func responseIsNotBatched(b []byte) bool {
@@ -517,6 +1039,99 @@ type BackendGroup struct {
 	Name      string
 	Backends  []*Backend
 	Consensus *ConsensusPoller
+	// ChainID namespaces the group when the process serves several chains, see ChainConsensusPollers
+	ChainID string
+}
+
+// metricsName returns the backend_group_name label of the group, prefixed with its chain id if any,
+// so groups of different chains sharing a name don't collide
+func (b *BackendGroup) metricsName() string {
+	if b.ChainID == "" {
+		return b.Name
+	}
+	return b.ChainID + "/" + b.Name
+}
+
+// DrainBackend stops routing new requests to the backend with the given name, and removes it from
+// the consensus, while letting its in-flight requests complete. Unlike a ban, draining has no expiry
+// and lasts until UndrainBackend is called
+func (b *BackendGroup) DrainBackend(name string) error {
+	be := b.getBackend(name)
+	if be == nil {
+		return fmt.Errorf("unknown backend %s in group %s", name, b.Name)
+	}
+	be.setDraining(true)
+	if b.Consensus != nil {
+		b.Consensus.removeFromConsensusGroup(be)
+	}
+	log.Info("draining backend", "group", b.Name, "name", name)
+	return nil
+}
+
+// UndrainBackend makes a drained backend available again for routing and consensus
+func (b *BackendGroup) UndrainBackend(name string) error {
+	be := b.getBackend(name)
+	if be == nil {
+		return fmt.Errorf("unknown backend %s in group %s", name, b.Name)
+	}
+	be.setDraining(false)
+	log.Info("undraining backend", "group", b.Name, "name", name)
+	return nil
+}
+
+// UpdateBackendURL repoints the backend with the given name to another rpc URL without restarting. Its
+// consensus state and bans are reset, so it has to catch up with the group from the new endpoint. Requests
+// in flight complete against the previous URL, and the blocks they return are not recorded
+func (b *BackendGroup) UpdateBackendURL(name string, rpcURL string) error {
+	be := b.getBackend(name)
+	if be == nil {
+		return fmt.Errorf("unknown backend %s in group %s", name, b.Name)
+	}
+	if rpcURL == "" {
+		return fmt.Errorf("empty rpc URL for backend %s in group %s", name, b.Name)
+	}
+	// the unix socket is wired in the transport of the backend client, which can't be swapped safely
+	if be.socketPath != "" || isUnixSocketURL(rpcURL) {
+		return fmt.Errorf("unix socket rpc URL of backend %s in group %s can't be swapped at runtime", name, b.Name)
+	}
+	be.setRPCURL(rpcURL)
+	if b.Consensus != nil {
+		b.Consensus.ResetBackend(be)
+	}
+	log.Info("updated backend rpc URL", "group", b.Name, "name", name)
+	return nil
+}
+
+// orderedBackends returns the backends in the order requests are routed to them: with a consensus, the
+// backends at the consensus hash come first, then the rest of the routing group, then the other backends.
+// Within the first two tiers, the local backends come first
+func (b *BackendGroup) orderedBackends() []*Backend {
+	if b.Consensus == nil {
+		return b.Backends
+	}
+
+	ordered := make([]*Backend, 0, len(b.Backends))
+	seen := make(map[*Backend]bool, len(b.Backends))
+	atConsensusHash := preferLocal(b.Consensus.GetBackendsAtConsensusHash())
+	group := preferLocal(b.Consensus.GetRoutingGroup())
+	for _, backends := range [][]*Backend{atConsensusHash, group, b.Backends} {
+		for _, be := range backends {
+			if !seen[be] {
+				seen[be] = true
+				ordered = append(ordered, be)
+			}
+		}
+	}
+	return ordered
+}
+
+func (b *BackendGroup) getBackend(name string) *Backend {
+	for _, be := range b.Backends {
+		if be.Name == name {
+			return be
+		}
+	}
+	return nil
 }
 
 func (b *BackendGroup) Forward(ctx context.Context, rpcReqs []*RPCReq, isBatch bool) ([]*RPCRes, error) {
@@ -526,7 +1141,38 @@ func (b *BackendGroup) Forward(ctx context.Context, rpcReqs []*RPCReq, isBatch b
 
 	rpcRequestsTotal.Inc()
 
-	for _, back := range b.Backends {
+	if b.Consensus != nil {
+		if err := b.Consensus.rejectRequests(); err != nil {
+			log.Warn(
+				"rejecting request without consensus",
+				"group", b.Name,
+				"auth", GetAuthCtx(ctx),
+				"req_id", GetReqID(ctx),
+				"err", err,
+			)
+			RecordUnserviceableRequest(ctx, RPCRequestSourceHTTP)
+			return nil, &NoConsensusError{Reason: err}
+		}
+	}
+
+	var answered []*RPCRes
+	if b.Consensus != nil {
+		rpcReqs, answered = b.Consensus.rewriteBlockTags(rpcReqs)
+		if len(rpcReqs) == 0 {
+			return answered, nil
+		}
+	}
+
+	for _, back := range b.orderedBackends() {
+		if back.IsDraining() {
+			log.Debug(
+				"skipping draining backend",
+				"name", back.Name,
+				"auth", GetAuthCtx(ctx),
+				"req_id", GetReqID(ctx),
+			)
+			continue
+		}
 		res, err := back.Forward(ctx, rpcReqs, isBatch)
 		if errors.Is(err, ErrMethodNotWhitelisted) {
 			return nil, err
@@ -559,15 +1205,56 @@ func (b *BackendGroup) Forward(ctx context.Context, rpcReqs []*RPCReq, isBatch b
 			)
 			continue
 		}
-		return res, nil
+		if b.Consensus != nil {
+			b.Consensus.capBlockNumbers(rpcReqs, res)
+		}
+		return mergeResponses(answered, res), nil
 	}
 
 	RecordUnserviceableRequest(ctx, RPCRequestSourceHTTP)
 	return nil, ErrNoBackends
 }
 
+// mergeResponses returns the responses of the requests answered without the backends, interleaved in order
+// with the responses of the forwarded ones, in the nil slots of answered
+func mergeResponses(answered []*RPCRes, forwarded []*RPCRes) []*RPCRes {
+	if answered == nil {
+		return forwarded
+	}
+	next := 0
+	for i := range answered {
+		if answered[i] == nil && next < len(forwarded) {
+			answered[i] = forwarded[next]
+			next++
+		}
+	}
+	return answered
+}
+
 func (b *BackendGroup) ProxyWS(ctx context.Context, clientConn *websocket.Conn, methodWhitelist *StringSet) (*WSProxier, error) {
+	if b.Consensus != nil {
+		if err := b.Consensus.rejectRequests(); err != nil {
+			log.Warn(
+				"rejecting ws connection without consensus",
+				"group", b.Name,
+				"auth", GetAuthCtx(ctx),
+				"req_id", GetReqID(ctx),
+				"err", err,
+			)
+			return nil, &NoConsensusError{Reason: err}
+		}
+	}
+
 	for _, back := range b.Backends {
+		if back.IsDraining() {
+			log.Debug(
+				"skipping draining backend",
+				"name", back.Name,
+				"req_id", GetReqID(ctx),
+				"auth", GetAuthCtx(ctx),
+			)
+			continue
+		}
 		proxier, err := back.ProxyWS(clientConn, methodWhitelist)
 		if errors.Is(err, ErrBackendOffline) {
 			log.Warn(
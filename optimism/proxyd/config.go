@@ -78,25 +78,85 @@ type BackendOptions struct {
 }
 
 type BackendConfig struct {
-	Username         string `toml:"username"`
-	Password         string `toml:"password"`
-	RPCURL           string `toml:"rpc_url"`
-	WSURL            string `toml:"ws_url"`
-	WSPort           int    `toml:"ws_port"`
-	MaxRPS           int    `toml:"max_rps"`
-	MaxWSConns       int    `toml:"max_ws_conns"`
-	CAFile           string `toml:"ca_file"`
-	ClientCertFile   string `toml:"client_cert_file"`
-	ClientKeyFile    string `toml:"client_key_file"`
-	StripTrailingXFF bool   `toml:"strip_trailing_xff"`
+	Username             string            `toml:"username"`
+	Password             string            `toml:"password"`
+	RPCURL               string            `toml:"rpc_url"`
+	WSURL                string            `toml:"ws_url"`
+	WSPort               int               `toml:"ws_port"`
+	MaxRPS               int               `toml:"max_rps"`
+	MaxWSConns           int               `toml:"max_ws_conns"`
+	CAFile               string            `toml:"ca_file"`
+	ClientCertFile       string            `toml:"client_cert_file"`
+	ClientKeyFile        string            `toml:"client_key_file"`
+	StripTrailingXFF     bool              `toml:"strip_trailing_xff"`
+	Weight               int               `toml:"weight"`
+	ConsensusVoting      *bool             `toml:"consensus_voting"`
+	ConsensusBlockMethod string            `toml:"consensus_block_method"`
+	Local                bool              `toml:"local"`
+	ConsensusMaxLatency  TOMLDuration      `toml:"consensus_max_latency"`
+	ConsensusTagMapping  map[string]string `toml:"consensus_tag_mapping"`
+	SyncStatusURL        string            `toml:"consensus_sync_status_url"`
 }
 
 type BackendsConfig map[string]*BackendConfig
 
 type BackendGroupConfig struct {
-	Backends              []string `toml:"backends"`
-	ConsensusAware        bool     `toml:"consensus_aware"`
-	ConsensusAsyncHandler string   `toml:"consensus_handler"`
+	Backends                           []string     `toml:"backends"`
+	ChainID                            string       `toml:"chain_id"`
+	ConsensusAware                     bool         `toml:"consensus_aware"`
+	ConsensusAsyncHandler              string       `toml:"consensus_handler"`
+	ConsensusMode                      string       `toml:"consensus_mode"`
+	ConsensusReliabilityWeighting      bool         `toml:"consensus_reliability_weighting"`
+	ConsensusReliabilityHalfLife       TOMLDuration `toml:"consensus_reliability_half_life"`
+	ConsensusRewindStrategy            string       `toml:"consensus_rewind_strategy"`
+	ConsensusBlockIDFormat             string       `toml:"consensus_block_id_format"`
+	ConsensusMaxBlockRange             int          `toml:"consensus_max_block_range"`
+	ConsensusFailMode                  string       `toml:"consensus_fail_mode"`
+	ConsensusCapBlockNumber            bool         `toml:"consensus_cap_block_number"`
+	ConsensusClampLogsRange            bool         `toml:"consensus_clamp_logs_range"`
+	ConsensusMonotonic                 bool         `toml:"consensus_monotonic"`
+	ConsensusRequireManualRollback     bool         `toml:"consensus_require_manual_rollback"`
+	ConsensusQuorum                    int          `toml:"consensus_quorum"`
+	ConsensusSplitBrainDetection       bool         `toml:"consensus_split_brain_detection"`
+	ConsensusWarmupCycles              int          `toml:"consensus_warmup_cycles"`
+	ConsensusGroupDebounceCycles       int          `toml:"consensus_group_debounce_cycles"`
+	ConsensusStartupGracePeriod        TOMLDuration `toml:"consensus_startup_grace_period"`
+	ConsensusBreakerProbationCycles    int          `toml:"consensus_breaker_probation_cycles"`
+	ConsensusForkDetectionCycles       int          `toml:"consensus_fork_detection_cycles"`
+	ConsensusRateLimitedStateMaxAge    TOMLDuration `toml:"consensus_rate_limited_state_max_age"`
+	ConsensusBlockTime                 TOMLDuration `toml:"consensus_block_time"`
+	ConsensusConfirmationDepth         int          `toml:"consensus_confirmation_depth"`
+	ConsensusConfirmationCycles        int          `toml:"consensus_confirmation_cycles"`
+	ConsensusMaxHeadRegression         int          `toml:"consensus_max_head_regression"`
+	ConsensusMaxHeadAdvance            int          `toml:"consensus_max_head_advance"`
+	ConsensusClockSkewTolerance        TOMLDuration `toml:"consensus_clock_skew_tolerance"`
+	ConsensusSlowPolls                 int          `toml:"consensus_slow_polls"`
+	ConsensusOutlierSensitivity        float64      `toml:"consensus_outlier_sensitivity"`
+	ConsensusStaleThreshold            TOMLDuration `toml:"consensus_stale_threshold"`
+	ConsensusLoadBalancerCheckInterval int          `toml:"consensus_load_balancer_check_interval"`
+	ConsensusFrozenBlockMultiplier     int          `toml:"consensus_frozen_block_multiplier"`
+	ConsensusCircuitBreakerFailures    int          `toml:"consensus_circuit_breaker_failures"`
+	ConsensusCircuitBreakerOpenPeriod  TOMLDuration `toml:"consensus_circuit_breaker_open_period"`
+	ConsensusSyncStatusHeads           bool         `toml:"consensus_sync_status_heads"`
+	ConsensusFinalizedOnly             bool         `toml:"consensus_finalized_only"`
+	ConsensusStrictChaining            bool         `toml:"consensus_strict_chaining"`
+	ConsensusWorkerPoolSize            int          `toml:"consensus_worker_pool_size"`
+	ConsensusPollSampleSize            int          `toml:"consensus_poll_sample_size"`
+	ConsensusPollBudget                int          `toml:"consensus_poll_budget"`
+	ConsensusPollBudgetWindow          TOMLDuration `toml:"consensus_poll_budget_window"`
+	ConsensusPollHTTP2                 bool         `toml:"consensus_poll_http2"`
+	ConsensusRefreshDebounce           TOMLDuration `toml:"consensus_refresh_debounce"`
+	ConsensusLazyTTL                   TOMLDuration `toml:"consensus_lazy_ttl"`
+	ConsensusAdaptivePolling           bool         `toml:"consensus_adaptive_polling"`
+	ConsensusMinPollInterval           TOMLDuration `toml:"consensus_min_poll_interval"`
+	ConsensusMaxPollInterval           TOMLDuration `toml:"consensus_max_poll_interval"`
+	ConsensusHistorySize               int          `toml:"consensus_history_size"`
+	ConsensusBackendBlockHistorySize   int          `toml:"consensus_backend_block_history_size"`
+	ConsensusMaxConcurrentFetches      int          `toml:"consensus_max_concurrent_fetches"`
+	ConsensusReferenceRPCURL           string       `toml:"consensus_reference_rpc_url"`
+	ConsensusReferenceMaxDivergence    int          `toml:"consensus_reference_max_divergence"`
+	ConsensusShadowBackends            []string     `toml:"consensus_shadow_backends"`
+	ConsensusWebhookURL                string       `toml:"consensus_webhook_url"`
 }
 
 type BackendGroupsConfig map[string]*BackendGroupConfig
@@ -0,0 +1,350 @@
+package proxyd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/semaphore"
+)
+
+// consensusHarness drives a ConsensusPoller over simulated backends. Each backend serves a scripted
+// timeline of chains, indexed by the ticks of a fake clock the test advances one poll cycle at a time.
+// The backends are served by an http.RoundTripper injected as the poller HTTP client, so the poller
+// runs its production fetch path without any listener
+type consensusHarness struct {
+	t  *testing.T
+	cp *ConsensusPoller
+
+	mtx       sync.Mutex
+	clock     int
+	timelines map[string][]harnessStep
+}
+
+// harnessStep is the state a backend takes from a tick on, until its next step
+type harnessStep struct {
+	tick   int
+	hashes []string
+	down   bool
+}
+
+// newConsensusHarness creates a poller over the named backends, which serve nothing until scripted
+func newConsensusHarness(t *testing.T, names []string, opts ...ConsensusOpt) *consensusHarness {
+	h := &consensusHarness{
+		t:         t,
+		timelines: make(map[string][]harnessStep),
+	}
+	backends := make([]*Backend, 0, len(names))
+	for _, name := range names {
+		backends = append(backends, NewBackend(name, "http://"+name, "", noopBackendRateLimiter, semaphore.NewWeighted(100), WithStrippedTrailingXFF()))
+	}
+	bg := &BackendGroup{
+		Name:     t.Name(),
+		Backends: backends,
+	}
+	opts = append([]ConsensusOpt{
+		WithAsyncHandler(NewNoopAsyncHandler()),
+		WithBlockIDNormalizer(NormalizeOpaqueBlockID),
+		WithPollerHTTPClient(&http.Client{Transport: h}),
+	}, opts...)
+	h.cp = NewConsensusPoller(bg, opts...)
+	bg.Consensus = h.cp
+	t.Cleanup(h.cp.Shutdown)
+	return h
+}
+
+// chain makes the backend serve blocks 0x1 up to the given hashes, each linked to the previous one,
+// with the last one as latest, from the given tick on
+func (h *consensusHarness) chain(name string, tick int, hashes ...string) {
+	h.script(name, harnessStep{tick: tick, hashes: hashes})
+}
+
+// down makes the backend fail every request from the given tick on, until its next chain
+func (h *consensusHarness) down(name string, tick int) {
+	h.script(name, harnessStep{tick: tick, down: true})
+}
+
+func (h *consensusHarness) script(name string, step harnessStep) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	timeline := h.timelines[name]
+	i := len(timeline)
+	for i > 0 && timeline[i-1].tick > step.tick {
+		i--
+	}
+	timeline = append(timeline, harnessStep{})
+	copy(timeline[i+1:], timeline[i:])
+	timeline[i] = step
+	h.timelines[name] = timeline
+}
+
+// current returns the step of the backend at the current tick, and false before its first step
+func (h *consensusHarness) current(name string) (harnessStep, bool) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	var step harnessStep
+	found := false
+	for _, s := range h.timelines[name] {
+		if s.tick > h.clock {
+			break
+		}
+		step, found = s, true
+	}
+	return step, found
+}
+
+// advance moves the clock forward by the given ticks, running a poll cycle of all the backends on each
+func (h *consensusHarness) advance(ticks int) {
+	for i := 0; i < ticks; i++ {
+		h.mtx.Lock()
+		h.clock++
+		h.mtx.Unlock()
+		updateConsensus(h.cp)
+	}
+}
+
+func (h *consensusHarness) backend(name string) *Backend {
+	be := h.cp.backendGroup.getBackend(name)
+	require.NotNil(h.t, be, name)
+	return be
+}
+
+func (h *consensusHarness) requireConsensus(blockNumber uint64, blockHash string) {
+	h.t.Helper()
+	require.Equal(h.t, hexutil.Uint64(blockNumber), h.cp.GetConsensusBlockNumber(), "tick %d", h.clock)
+	h.cp.consensusGroupMux.Lock()
+	consensusHash := h.cp.consensusHash
+	h.cp.consensusGroupMux.Unlock()
+	require.Equal(h.t, blockHash, consensusHash, "tick %d", h.clock)
+}
+
+func (h *consensusHarness) requireGroup(names ...string) {
+	h.t.Helper()
+	group := make([]string, 0, len(names))
+	for _, be := range h.cp.GetConsensusGroup() {
+		group = append(group, be.Name)
+	}
+	require.ElementsMatch(h.t, names, group, "tick %d", h.clock)
+}
+
+// RoundTrip serves the polling requests from the scripted state of the backend at the current tick
+func (h *consensusHarness) RoundTrip(req *http.Request) (*http.Response, error) {
+	step, ok := h.current(req.URL.Host)
+	if !ok || step.down {
+		return harnessResponse(req, http.StatusServiceUnavailable, nil), nil
+	}
+
+	var rpcReq RPCReq
+	if err := json.NewDecoder(req.Body).Decode(&rpcReq); err != nil {
+		return harnessResponse(req, http.StatusBadRequest, nil), nil
+	}
+	var params []interface{}
+	_ = json.Unmarshal(rpcReq.Params, &params)
+
+	result := "null"
+	if rpcReq.Method == "eth_getBlockByNumber" && len(params) > 0 {
+		number := uint64(len(step.hashes))
+		if tag, _ := params[0].(string); tag != "latest" {
+			parsed, err := parseQuantity(tag)
+			if err != nil {
+				return harnessResponse(req, http.StatusBadRequest, nil), nil
+			}
+			number = uint64(parsed)
+		}
+		if number >= 1 && number <= uint64(len(step.hashes)) {
+			parentHash := ""
+			if number > 1 {
+				parentHash = step.hashes[number-2]
+			}
+			result = fmt.Sprintf(`{"number": "%s", "hash": "%s", "parentHash": "%s", "timestamp": "%s"}`,
+				hexutil.Uint64(number), step.hashes[number-1], parentHash, hexutil.Uint64(number))
+		}
+	}
+	body := []byte(fmt.Sprintf(`{"jsonrpc": "2.0", "id": %s, "result": %s}`, rpcReq.ID, result))
+	return harnessResponse(req, http.StatusOK, body), nil
+}
+
+func harnessResponse(req *http.Request, status int, body []byte) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}
+}
+
+func TestConsensusHarnessReorg(t *testing.T) {
+	h := newConsensusHarness(t, []string{"node1", "node2", "node3"})
+	for _, name := range []string{"node1", "node2", "node3"} {
+		h.chain(name, 1, "a1", "a2", "a3")
+	}
+	// node1 reorgs first, the others follow a tick later
+	h.chain("node1", 3, "a1", "a2", "b3", "b4")
+	h.chain("node2", 4, "a1", "a2", "b3", "b4")
+	h.chain("node3", 4, "a1", "a2", "b3", "b4")
+
+	h.advance(2)
+	h.requireConsensus(3, "a3")
+	h.requireGroup("node1", "node2", "node3")
+
+	h.advance(1)
+	h.requireConsensus(2, "a2")
+	reorg, ok := h.cp.GetLastReorg()
+	require.True(t, ok)
+	require.Equal(t, hexutil.Uint64(3), reorg.OldBlockNumber)
+	require.Equal(t, "a3", reorg.OldBlockHash)
+
+	h.advance(1)
+	h.requireConsensus(4, "b4")
+	h.requireGroup("node1", "node2", "node3")
+}
+
+func TestConsensusHarnessDivergence(t *testing.T) {
+	h := newConsensusHarness(t, []string{"node1", "node2", "node3"}, WithConsensusMode(ConsensusModeQuorum), WithQuorum(2))
+	for _, name := range []string{"node1", "node2", "node3"} {
+		h.chain(name, 1, "a1", "a2")
+	}
+	h.chain("node1", 2, "a1", "a2", "a3")
+	h.chain("node2", 2, "a1", "a2", "a3")
+	// node3 builds its own chain from the second block
+	h.chain("node3", 2, "a1", "a2", "c3")
+	h.chain("node3", 3, "a1", "a2", "c3", "c4")
+
+	h.advance(1)
+	h.requireConsensus(2, "a2")
+	h.requireGroup("node1", "node2", "node3")
+
+	h.advance(1)
+	h.requireConsensus(3, "a3")
+	h.requireGroup("node1", "node2")
+
+	// node3 gets ahead on its fork, the majority still holds the consensus
+	h.advance(1)
+	h.requireConsensus(3, "a3")
+	h.requireGroup("node1", "node2")
+}
+
+func TestConsensusHarnessLag(t *testing.T) {
+	names := []string{"node1", "node2", "node3"}
+	chain := []string{"a1", "a2", "a3", "a4", "a5"}
+	scenario := func(h *consensusHarness) {
+		for tick := 1; tick <= 4; tick++ {
+			h.chain("node1", tick, chain[:tick+1]...)
+			h.chain("node2", tick, chain[:tick+1]...)
+		}
+		// node3 stalls at the second block
+		h.chain("node3", 1, chain[:2]...)
+		h.advance(4)
+	}
+
+	// the lowest block mode waits for the lagging backend
+	lowest := newConsensusHarness(t, names)
+	scenario(lowest)
+	lowest.requireConsensus(2, "a2")
+	lowest.requireGroup("node1", "node2", "node3")
+
+	// the quorum mode moves on without it
+	quorum := newConsensusHarness(t, names, WithConsensusMode(ConsensusModeQuorum), WithQuorum(2))
+	scenario(quorum)
+	quorum.requireConsensus(5, "a5")
+	quorum.requireGroup("node1", "node2")
+}
+
+func TestConsensusHarnessBanAndDown(t *testing.T) {
+	h := newConsensusHarness(t, []string{"node1", "node2", "node3"})
+	for _, name := range []string{"node1", "node2", "node3"} {
+		h.chain(name, 1, "a1", "a2")
+	}
+	h.chain("node1", 2, "a1", "a2", "a3")
+	h.chain("node3", 2, "a1", "a2", "a3")
+	h.chain("node2", 2, "a1", "a2", "b3")
+	h.chain("node1", 3, "a1", "a2", "a3", "a4")
+	h.down("node3", 3)
+
+	h.advance(1)
+	h.requireConsensus(2, "a2")
+	h.requireGroup("node1", "node2", "node3")
+
+	// node2 is banned before it diverges, it can't break the consensus
+	h.cp.Ban(h.backend("node2"), "test")
+	h.advance(1)
+	h.requireConsensus(3, "a3")
+	h.requireGroup("node1", "node3")
+	require.Len(t, h.cp.GetBannedBackends(), 1)
+
+	// node3 goes down, its last state holds the consensus back until it is taken offline
+	h.advance(1)
+	h.requireConsensus(3, "a3")
+}
+
+func TestConsensusHarnessReplay(t *testing.T) {
+	names := []string{"node1", "node2", "node3"}
+	type decision struct {
+		blockNumber hexutil.Uint64
+		blockHash   string
+		group       []string
+	}
+	decide := func(cp *ConsensusPoller) decision {
+		cp.consensusGroupMux.Lock()
+		defer cp.consensusGroupMux.Unlock()
+		d := decision{blockNumber: cp.tracker.GetConsensusBlockNumber(), blockHash: cp.consensusHash}
+		for _, be := range cp.consensusGroup {
+			d.group = append(d.group, be.Name)
+		}
+		return d
+	}
+
+	// a reorg is recorded as it unfolds: node1 reorgs first, node3 goes down for a cycle
+	recorder := NewPollRecorder(0)
+	h := newConsensusHarness(t, names, WithPollRecorder(recorder))
+	for _, name := range names {
+		h.chain(name, 1, "a1", "a2", "a3")
+	}
+	h.chain("node1", 3, "a1", "a2", "b3", "b4")
+	h.chain("node2", 4, "a1", "a2", "b3", "b4")
+	h.down("node3", 4)
+	h.chain("node3", 5, "a1", "a2", "b3", "b4", "b5")
+	h.chain("node1", 5, "a1", "a2", "b3", "b4", "b5")
+	h.chain("node2", 5, "a1", "a2", "b3", "b4", "b5")
+
+	var recorded []decision
+	for tick := 1; tick <= 6; tick++ {
+		h.advance(1)
+		recorded = append(recorded, decide(h.cp))
+	}
+	reorg, ok := h.cp.GetLastReorg()
+	require.True(t, ok)
+	recording, err := recorder.Recording()
+	require.NoError(t, err)
+
+	// the replay takes the same decisions, cycle by cycle, without the simulated backends
+	replay, err := NewReplayFetcher(recording)
+	require.NoError(t, err)
+	backends := make([]*Backend, 0, len(names))
+	for _, name := range names {
+		backends = append(backends, NewBackend(name, "http://"+name, "", noopBackendRateLimiter, semaphore.NewWeighted(100), WithStrippedTrailingXFF()))
+	}
+	bg := &BackendGroup{Name: t.Name(), Backends: backends}
+	cp := NewConsensusPoller(bg, WithAsyncHandler(NewNoopAsyncHandler()), WithBlockIDNormalizer(NormalizeOpaqueBlockID), WithPollReplay(replay))
+	t.Cleanup(cp.Shutdown)
+	for i, expected := range recorded {
+		updateConsensus(cp)
+		actual := decide(cp)
+		require.Equal(t, expected.blockNumber, actual.blockNumber, "cycle %d", i+1)
+		require.Equal(t, expected.blockHash, actual.blockHash, "cycle %d", i+1)
+		require.ElementsMatch(t, expected.group, actual.group, "cycle %d", i+1)
+	}
+	replayedReorg, ok := cp.GetLastReorg()
+	require.True(t, ok)
+	require.Equal(t, reorg.OldBlockHash, replayedReorg.OldBlockHash)
+	require.Equal(t, reorg.NewBlockHash, replayedReorg.NewBlockHash)
+
+	_, err = NewReplayFetcher([]byte("not a recording"))
+	require.Error(t, err)
+}
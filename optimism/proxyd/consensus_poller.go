@@ -1,330 +1,5411 @@
 package proxyd
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/semaphore"
 
 	"github.com/ethereum/go-ethereum/log"
 )
 
 const (
 	PollerInterval = 1 * time.Second
+
+	// latencyEWMAWeight is the weight given to the most recent sample in the backend latency average
+	latencyEWMAWeight = 0.2
+
+	// reliabilityEWMAWeight is the weight given to the most recent poll outcome in the backend reliability score
+	reliabilityEWMAWeight = 0.1
+
+	// maxErrorBackoffDoublings caps the growth of the error backoff of a backend failing repeatedly
+	maxErrorBackoffDoublings = 5
+
+	// DefaultGroupStateLogInterval is the number of cycles between two logs of an unchanged group state
+	DefaultGroupStateLogInterval = 60
+
+	// DefaultBanPeriod is how long a banned backend is left out of the consensus
+	DefaultBanPeriod = 5 * time.Minute
+
+	// DefaultWorkerPoolSize is the number of concurrent block fetches while validating the consensus
+	DefaultWorkerPoolSize = 10
+
+	// DefaultErrorBackoff is how long a backend is left unpolled after an error classified as FetchErrorBackoff
+	DefaultErrorBackoff = 10 * time.Second
+
+	// DefaultCompareDepth is how many blocks CompareBackends walks back looking for a common block
+	DefaultCompareDepth = 256
+
+	// DefaultConsensusHistorySize is the number of past consensus blocks kept for GetRecentConsensus
+	DefaultConsensusHistorySize = 128
+
+	// DefaultBackendBlockHistorySize is the number of past polls kept per backend for GetBackendBlockHistory
+	DefaultBackendBlockHistorySize = 64
+
+	// DefaultRefreshDebounce is the window where the calls to TriggerRefresh are coalesced in a single refresh
+	DefaultRefreshDebounce = 100 * time.Millisecond
+
+	// DefaultWebhookMinInterval is the minimum time between two webhook notifications of the same event
+	DefaultWebhookMinInterval = time.Minute
+
+	// minHealthyReliability is the reliability score under which a backend is reported degraded
+	minHealthyReliability = 0.8
+
+	// DefaultSlowPolls is the number of consecutive polls over its max latency before a backend is excluded from voting
+	DefaultSlowPolls = 3
+
+	// DefaultMinPollInterval and DefaultMaxPollInterval bound the poll interval with adaptive polling
+	DefaultMinPollInterval = 250 * time.Millisecond
+	DefaultMaxPollInterval = 5 * time.Second
+
+	// blockTimeEWMAWeight is the weight given to the most recent sample in the observed block time average
+	blockTimeEWMAWeight = 0.2
+
+	// adaptivePollRatio is the poll interval with adaptive polling, as a fraction of the observed block time
+	adaptivePollRatio = 0.75
+)
+
+// FetchErrorAction is the response of the poller to an error polling a backend
+type FetchErrorAction int
+
+const (
+	// FetchErrorIgnore polls the backend again on the next cycle
+	FetchErrorIgnore FetchErrorAction = iota
+	// FetchErrorBackoff leaves the backend unpolled for the error backoff period
+	FetchErrorBackoff
+	// FetchErrorBan bans the backend from the consensus for the ban period
+	FetchErrorBan
+)
+
+// FetchErrorClassifier maps an error polling a backend to the action the poller takes
+type FetchErrorClassifier func(be *Backend, err error) FetchErrorAction
+
+var (
+	// ErrConsensusNotReady is returned by CheckReady when no consensus was computed yet. The other
+	// not-ready conditions wrap it, so errors.Is(err, ErrConsensusNotReady) matches all of them
+	ErrConsensusNotReady = errors.New("consensus not ready")
+	// ErrConsensusGroupEmpty is returned by CheckReady when no backend is in the consensus group
+	ErrConsensusGroupEmpty = fmt.Errorf("%w: no backend in the consensus group", ErrConsensusNotReady)
+	// ErrConsensusDegraded is returned by CheckReady when the consensus group is smaller than the quorum
+	ErrConsensusDegraded = fmt.Errorf("%w: consensus group below the quorum", ErrConsensusNotReady)
+	// ErrConsensusStale is returned by CheckReady and GetFreshConsensusBlockNumber when no cycle committed
+	// the consensus for longer than the stale threshold, i.e. the poller stalled
+	ErrConsensusStale = fmt.Errorf("%w: consensus older than the stale threshold", ErrConsensusNotReady)
+	// ErrConsensusSplitBrain is returned by CheckReady when several hash clusters meet the quorum at the
+	// same height, see WithSplitBrainDetection
+	ErrConsensusSplitBrain = fmt.Errorf("%w: split brain", ErrConsensusNotReady)
+)
+
+// ErrInconsistentHead is reported to the FetchErrorClassifier when the latest block of a backend
+// regresses further than allowed, i.e. a load-balanced upstream flipping between nodes
+var ErrInconsistentHead = errors.New("inconsistent latest block")
+
+// ErrInconsistentEndpoint is reported to the FetchErrorClassifier when two successive fetches of the latest
+// block of a backend return different hashes at the same height, i.e. its URL fronts several nodes
+var ErrInconsistentEndpoint = errors.New("inconsistent endpoint")
+
+// ErrClockSkew is reported to the FetchErrorClassifier when the latest block of a backend is dated further
+// in the future than the clock skew tolerance, i.e. the clock of the node or of its upstream is off
+var ErrClockSkew = errors.New("latest block dated in the future")
+
+// CircuitState is the state of the circuit breaker pausing the polling of a failing backend
+type CircuitState int
+
+const (
+	// CircuitClosed polls the backend normally
+	CircuitClosed CircuitState = iota
+	// CircuitOpen pauses the polling of the backend after sustained failure
+	CircuitOpen
+	// CircuitHalfOpen probes the backend with a single poll, closing the circuit on success
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// BackendHealth summarizes the state of a backend in the consensus, from its online status, its ban,
+// its consensus group membership and its recent poll failures, see GetBackendHealth
+type BackendHealth int
+
+const (
+	// BackendHealthy is online, in the consensus group, and polls reliably
+	BackendHealthy BackendHealth = iota
+	// BackendDegraded is online and not banned, but out of the consensus group, excluded from voting,
+	// failing its last poll, or below the healthy reliability score
+	BackendDegraded
+	// BackendBanned is banned from the consensus
+	BackendBanned
+	// BackendOffline is offline, or its polling is paused by the circuit breaker
+	BackendOffline
+)
+
+func (h BackendHealth) String() string {
+	switch h {
+	case BackendDegraded:
+		return "degraded"
+	case BackendBanned:
+		return "banned"
+	case BackendOffline:
+		return "offline"
+	default:
+		return "healthy"
+	}
+}
+
+// ConsensusHealth summarizes the state of the consensus of the group, computed each consensus cycle,
+// see GetConsensusHealth
+type ConsensusHealth int
+
+const (
+	// ConsensusHealthy has every backend of the group in the consensus group
+	ConsensusHealthy ConsensusHealth = iota
+	// ConsensusDegraded has a consensus, but some backends of the group are out of the consensus group,
+	// i.e. banned, excluded from voting, offline or disagreeing
+	ConsensusDegraded
+	// NoConsensus has no consensus block, an empty consensus group, or a split brain
+	NoConsensus
+)
+
+func (h ConsensusHealth) String() string {
+	switch h {
+	case ConsensusDegraded:
+		return "degraded"
+	case NoConsensus:
+		return "no consensus"
+	default:
+		return "healthy"
+	}
+}
+
+// BlockIDNormalizer validates a block identifier reported by a backend, i.e. a block hash, and returns its
+// canonical form so the identifiers of the same block compare equal. A malformed identifier fails the fetch
+type BlockIDNormalizer func(id string) (string, error)
+
+// BlockIDFormat names a BlockIDNormalizer in the configuration
+type BlockIDFormat string
+
+const (
+	// BlockIDFormatEVM accepts the standard EVM block hashes, see NormalizeEVMBlockHash
+	BlockIDFormatEVM BlockIDFormat = "evm"
+	// BlockIDFormatOpaque accepts any block identifier, see NormalizeOpaqueBlockID
+	BlockIDFormatOpaque BlockIDFormat = "opaque"
+)
+
+// NormalizeEVMBlockHash accepts 0x-prefixed 32-byte hex block hashes, and returns them in lower case
+func NormalizeEVMBlockHash(id string) (string, error) {
+	id = strings.ToLower(id)
+	b, err := hexutil.Decode(id)
+	if err != nil {
+		return "", fmt.Errorf("invalid block hash %q: %w", id, err)
+	}
+	if len(b) != 32 {
+		return "", fmt.Errorf("invalid block hash %q: %d bytes instead of 32", id, len(b))
+	}
+	return id, nil
+}
+
+// NormalizeOpaqueBlockID accepts any non-empty block identifier as is, for chains identifying their
+// blocks in a non-standard format
+func NormalizeOpaqueBlockID(id string) (string, error) {
+	if id == "" {
+		return "", errors.New("empty block identifier")
+	}
+	return id, nil
+}
+
+// ConsensusMode selects the algorithm used to resolve the group consensus
+type ConsensusMode string
+
+const (
+	// ConsensusModeLowestBlock anchors the consensus on the lowest block and requires all the backends to agree
+	ConsensusModeLowestBlock ConsensusMode = "lowest_block"
+	// ConsensusModeQuorum picks the highest block where a quorum of backends agree
+	ConsensusModeQuorum ConsensusMode = "quorum"
+	// ConsensusModeWeightedMedian picks the weighted median of the backends latest blocks,
+	// without requiring all the backends to agree
+	ConsensusModeWeightedMedian ConsensusMode = "weighted_median"
+	// ConsensusModeSingleBackend tracks the head of the only backend of the group, without validating it,
+	// to smoke test the connectivity and the parsing end-to-end against a single backend
+	ConsensusModeSingleBackend ConsensusMode = "single_backend"
+	// ConsensusModeFinalizedQuorum picks the highest finalized block where a quorum of backends agree,
+	// as the quorum mode does on the finalized heads, see WithFinalizedOnly
+	ConsensusModeFinalizedQuorum ConsensusMode = "finalized_quorum"
+	// ConsensusModeHeadOnly tracks the lowest head number of the backends, polled with eth_blockNumber, without
+	// fetching nor comparing any block hash. It smooths the head across the backends with no reorg protection
+	ConsensusModeHeadOnly ConsensusMode = "head_only"
+)
+
+// RewindStrategy selects how the lowest block mode walks back to find the block the backends agree on
+type RewindStrategy string
+
+const (
+	// RewindLinear walks back one block at a time
+	RewindLinear RewindStrategy = "linear"
+	// RewindBatched jumps back by doubling steps, then bisects to the highest agreed block
+	RewindBatched RewindStrategy = "batched"
+)
+
+// FailMode selects how routing behaves when there is no consensus group
+type FailMode string
+
+const (
+	// FailOpen keeps serving requests from all the backends when there is no consensus
+	FailOpen FailMode = "open"
+	// FailClosed rejects requests when the consensus is not ready, see CheckReady
+	FailClosed FailMode = "closed"
 )
 
 // ConsensusPoller checks the consensus state for each member of a BackendGroup
 // resolves the highest common block for multiple nodes, and reconciles the consensus
 // in case of block hash divergence to minimize re-orgs
 type ConsensusPoller struct {
+	ctx        context.Context
 	cancelFunc context.CancelFunc
 
 	backendGroup      *BackendGroup
 	backendState      map[*Backend]*backendState
 	consensusGroupMux sync.Mutex
 	consensusGroup    []*Backend
+	consensusHash     string
+	// consensusTimestamp is the timestamp of the consensus block, zero when it could not be fetched
+	consensusTimestamp uint64
+	// lastReorg describes the last committed consensus broken event, guarded by consensusGroupMux
+	lastReorg *ReorgInfo
+	// committedAt is when a cycle last committed the consensus, zero until the first one or after a restore,
+	// guarded by consensusGroupMux
+	committedAt time.Time
+	// staleThreshold is how long after the last commit the consensus is reported stale; zero disables the guard
+	staleThreshold time.Duration
+	// routingGroup is the consensus group visible to the routing, lagging behind the consensus group by
+	// groupDebounceCycles, guarded by consensusGroupMux
+	routingGroup []*Backend
+	// routingGroupStreaks counts the consecutive cycles a backend membership differs from the routing group,
+	// guarded by consensusGroupMux
+	routingGroupStreaks map[*Backend]int
+	// groupDebounceCycles is the number of consecutive cycles a backend must be in or out of the consensus group
+	// before the routing group reflects it; zero routes to the consensus group directly
+	groupDebounceCycles int
+	// splitBrain is set while several hash clusters meet the quorum at the same height, guarded by consensusGroupMux
+	splitBrain bool
+	// health is the health of the consensus as of the last cycle, guarded by consensusGroupMux
+	health ConsensusHealth
+	// consensusHistory is a ring buffer of the last consensus blocks, guarded by consensusGroupMux
+	consensusHistory      []ConsensusEntry
+	consensusHistoryStart int
+	consensusHistorySize  int
+	// backendBlockHistorySize is the number of past polls kept in the block history of each backend
+	backendBlockHistorySize int
 
 	tracker      ConsensusTracker
 	asyncHandler ConsensusAsyncHandler
+	client       *http.Client
+	// pollHTTP2 polls the backends over HTTP/2 when they negotiate it, see WithPollerHTTP2
+	pollHTTP2 bool
+	// pollRecorder records the polls of the backends, see WithPollRecorder
+	pollRecorder *PollRecorder
+	// pollReplay serves the polls of the backends from a recording, see WithPollReplay
+	pollReplay *ReplayFetcher
+
+	// workers bounds the concurrent block fetches across the poller
+	workers        *semaphore.Weighted
+	workerPoolSize int
+	// pollSampleSize, when set, is the number of backends polled per cycle, rotating through the group
+	pollSampleSize   int
+	pollSampleOffset int
+	pollSampleMux    sync.Mutex
+
+	// adaptivePolling derives the poll interval from the block time observed between consensus advances,
+	// bounded by minPollInterval and maxPollInterval. The observation is guarded by blockTimeMux
+	adaptivePolling        bool
+	minPollInterval        time.Duration
+	maxPollInterval        time.Duration
+	blockTimeMux           sync.Mutex
+	observedBlockTime      time.Duration
+	lastAdvanceBlockNumber hexutil.Uint64
+	lastAdvanceTime        time.Time
+
+	// fetches, when set, bounds the in-flight requests to the backends across all the poller goroutines. Unlike the
+	// worker pool, which bounds the goroutines a validation step fans out to, it also covers the polls of the latest
+	// blocks, run by the async handler outside of the pool
+	fetches              *semaphore.Weighted
+	maxConcurrentFetches int
+
+	mode                ConsensusMode
+	rewindStrategy      RewindStrategy
+	maxBlockRange       uint64
+	failMode            FailMode
+	capBlockNumber      bool
+	clampLogsRange      bool
+	monotonic           bool
+	manualRollback      bool
+	quorum              int
+	warmupCycles        int
+	probationCycles     int
+	verifyTransactions  bool
+	strictChaining      bool
+	syncStatusHeads     bool
+	forkDetectionCycles int
+	// finalizedOnly computes the consensus on the finalized heads of the backends, ignoring their latest blocks
+	finalizedOnly bool
+
+	// reference is a trusted endpoint polled to check the consensus against, never routed to. An alert is
+	// raised when the consensus is more than referenceMaxDivergence blocks away from it, or disagrees on a hash
+	reference              *Backend
+	referenceURL           string
+	referenceMaxDivergence uint64
+
+	// webhookURL, when set, receives a POST for every significant consensus event, at most one per event
+	// type per webhookMinInterval. The events are queued in webhookEvents and posted by a single goroutine
+	webhookURL         string
+	webhookMinInterval time.Duration
+	webhookClient      *http.Client
+	webhookEvents      chan ConsensusEvent
+	webhookLastSent    map[ConsensusEventType]time.Time
+	webhookMux         sync.Mutex
+
+	// shadowBackends are polled every cycle and compared against the consensus, but never voted with nor
+	// routed to, to trial new backends before adding them to the group
+	shadowBackends []*Backend
+
+	// reliabilityWeighting scales the weight of the backends in weighted median mode by their reliability score
+	reliabilityWeighting bool
+	// splitBrainDetection refuses to pick a winner in quorum mode when several hash clusters meet the quorum
+	splitBrainDetection bool
+	// reliabilityHalfLife is the time after which the impact of a failed or diverging poll on the reliability
+	// score is halved; zero only decays it with the later polls
+	reliabilityHalfLife time.Duration
+
+	// paused freezes the consensus block and suspends the bans and breakers, while the backends are still polled
+	paused    bool
+	pausedMux sync.Mutex
+	// rollbackPending is set while the consensus is paused on a coordinated rollback, which the next ResumeConsensus
+	// confirms by setting rollbackConfirmed, both guarded by pausedMux
+	rollbackPending   bool
+	rollbackConfirmed bool
+
+	// startedAt and startupGracePeriod define the window after startup where divergence
+	// is only logged, without bans or consensus broken events
+	startedAt          time.Time
+	startupGracePeriod time.Duration
+
+	// maxHeadRegression is how many blocks the latest block of a backend may move back, i.e. on a reorg,
+	// before it is considered inconsistent; zero disables the check
+	maxHeadRegression uint64
+	// maxHeadAdvance is how many blocks the latest block of a backend may move ahead of both its previous
+	// latest block and the highest block of the other backends; zero disables the check
+	maxHeadAdvance uint64
+
+	// slowPolls is the number of consecutive polls over its max latency before a backend is excluded from voting
+	slowPolls int
+
+	// clockSkewTolerance is how far in the future the latest block of a backend may be dated, to allow for
+	// the clock drift between the block producer and proxyd, before the backend is flagged; zero disables the check
+	clockSkewTolerance time.Duration
+
+	// confirmationDepth is how many blocks behind the head of the backends the consensus is computed,
+	// for extra reorg safety; zero computes it at the head
+	confirmationDepth uint64
+	// confirmationCycles is the number of cycles the consensus block must be held before it is confirmed,
+	// zero confirms it as soon as it is reached
+	confirmationCycles uint64
+	// confirmedBlockNumber is the last consensus block held for confirmationCycles, guarded by consensusGroupMux
+	confirmedBlockNumber hexutil.Uint64
+
+	// loadBalancerCheckInterval is every how many polls of a backend its latest block is fetched twice,
+	// to detect an endpoint load-balancing across nodes; zero disables the check
+	loadBalancerCheckInterval int
+
+	// outlierSensitivity is how many median absolute deviations the latest block number of a backend may be
+	// away from the median of the group before it is excluded as an outlier; zero disables the detection
+	outlierSensitivity float64
+
+	// frozenThreshold is how long the latest block of a backend may stay unchanged while its peers
+	// advance, before it is banned as serving from a stale cache; zero disables the detection
+	frozenThreshold time.Duration
+
+	// blockIDNormalizer validates and canonicalizes the block hashes fetched from the backends
+	blockIDNormalizer BlockIDNormalizer
+
+	// rateLimitedStateMaxAge is how long the cached state of a rate-limited backend still counts
+	// toward the consensus; zero skips rate-limited backends
+	rateLimitedStateMaxAge time.Duration
+
+	errorClassifier FetchErrorClassifier
+	banPeriod       time.Duration
+	errorBackoff    time.Duration
+
+	// pollBudget is the number of polling requests each backend may be sent per pollBudgetWindow, beyond which
+	// its polls are skipped until the next window; zero doesn't limit the polling
+	pollBudget       int
+	pollBudgetWindow time.Duration
+
+	// circuitFailureThreshold is the number of consecutive failed polls opening the circuit of a backend,
+	// pausing its polling for circuitOpenPeriod before a probe; zero disables the circuit breaker
+	circuitFailureThreshold int
+	circuitOpenPeriod       time.Duration
+
+	groupStateLogInterval int
+	// lastGroupStateLog is guarded by cycleMux
+	lastGroupStateLog groupStateLog
+
+	// cycleMux serializes the group consensus cycles of the timer, TriggerRefresh and the lazy mode,
+	// so a cycle can't commit its proposal after the one of a later cycle
+	cycleMux sync.Mutex
+
+	logger log.Logger
+
+	observersMux sync.Mutex
+	observers    []chan CycleResult
+	// blockSubscribers receive the consensus block number on every change, closed on shutdown
+	blockSubscribers []chan hexutil.Uint64
+	shutdown         bool
+
+	// refreshC coalesces the calls to TriggerRefresh, served by a goroutine started on the first call
+	refreshC        chan struct{}
+	refreshOnce     sync.Once
+	refreshDebounce time.Duration
+
+	// lazyTTL, when set, recomputes the consensus when it is queried and older than the TTL instead of on a
+	// timer, see WithLazyConsensus. lazyMux serializes the lazy refreshes, lazyRefreshedAt is the last one
+	lazyTTL         time.Duration
+	lazyMux         sync.Mutex
+	lazyRefreshedAt time.Time
+}
+
+// ConsensusEntry is a consensus block committed by the poller
+type ConsensusEntry struct {
+	BlockNumber hexutil.Uint64
+	BlockHash   string
+	Time        time.Time
+}
+
+// ReorgInfo describes a consensus broken event, i.e. a reorg detected by a backend disagreeing
+// on a block at or below the consensus block
+type ReorgInfo struct {
+	Time           time.Time
+	OldBlockNumber hexutil.Uint64
+	OldBlockHash   string
+	NewBlockNumber hexutil.Uint64
+	NewBlockHash   string
+	// Backend is the name of the backend that broke the consensus first
+	Backend string
+}
+
+// BackendBlockEntry is the latest block of a backend at a poll
+type BackendBlockEntry struct {
+	BlockNumber hexutil.Uint64
+	BlockHash   string
+	Time        time.Time
+}
+
+// CycleResult describes the outcome of a group consensus cycle
+type CycleResult struct {
+	BlockNumber      hexutil.Uint64
+	BlockHash        string
+	ConsensusGroup   []string
+	FilteredBackends []string
+	Broken           bool
+	Duration         time.Duration
+	// Committed is false when the cycle committed no proposal, i.e. there was no block to propose, or the
+	// consensus is paused. The block and the group are then those of the consensus left in place
+	Committed bool
+}
+
+// ConsensusEventType names a significant consensus event notified to the webhook
+type ConsensusEventType string
+
+const (
+	// ConsensusEventReorg is sent when the consensus moves to another hash at or below the consensus block,
+	// or a backend disagrees on a block at or below it
+	ConsensusEventReorg ConsensusEventType = "reorg"
+	// ConsensusEventGroupShrink is sent when the consensus group loses backends
+	ConsensusEventGroupShrink ConsensusEventType = "group_shrink"
+	// ConsensusEventAllDown is sent when no backend of the group is available to compute the consensus
+	ConsensusEventAllDown ConsensusEventType = "all_down"
+	// ConsensusEventRollbackHeld is sent when the consensus is paused on a coordinated rollback, see WithRequireManualRollback
+	ConsensusEventRollbackHeld ConsensusEventType = "rollback_held"
+)
+
+// ConsensusEvent is the JSON payload posted to the webhook
+type ConsensusEvent struct {
+	Event               ConsensusEventType `json:"event"`
+	Group               string             `json:"group"`
+	BlockNumber         hexutil.Uint64     `json:"block_number"`
+	BlockHash           string             `json:"block_hash,omitempty"`
+	PreviousBlockNumber hexutil.Uint64     `json:"previous_block_number"`
+	ConsensusGroup      []string           `json:"consensus_group"`
+	Time                time.Time          `json:"time"`
+}
+
+// webhookBufferSize is the number of events queued for the webhook before new ones are dropped
+const webhookBufferSize = 16
+
+// observerBufferSize is the number of cycle results queued for a slow observer before new ones are dropped
+const observerBufferSize = 16
+
+// blockSubscriberBufferSize is the number of consensus block numbers queued for a slow subscriber
+// before the oldest ones are dropped
+const blockSubscriberBufferSize = 16
+
+// waitReadyInterval is how often WaitReady checks the consensus
+const waitReadyInterval = 50 * time.Millisecond
+
+// groupStateLog keeps track of the last logged group state, to sample the routine logs
+type groupStateLog struct {
+	blockNumber     hexutil.Uint64
+	consensusGroup  string
+	filteredGroup   string
+	cyclesSinceLast int
 }
 
 type backendState struct {
 	backendStateMux sync.Mutex
+	backendStateValues
+
+	// pollClient is the client polling the backend in place of the poller and backend ones, set once
+	// at construction, see WithPollerHTTP2, WithPollRecorder and WithPollReplay
+	pollClient *http.Client
+}
 
+// backendStateValues holds the fields of a backendState guarded by its lock, apart so they can be reset at once
+type backendStateValues struct {
 	latestBlockNumber hexutil.Uint64
 	latestBlockHash   string
 
 	lastUpdate time.Time
+	// lastChange is when the latest block hash of the backend last changed
+	lastChange time.Time
+
+	// blockHistory is a ring buffer of the latest blocks of the last polls
+	blockHistory      []BackendBlockEntry
+	blockHistoryStart int
 
 	bannedUntil time.Time
+	banReason   string
+	// backoffUntil is set after an error classified as FetchErrorBackoff, the backend is not polled until then
+	backoffUntil time.Time
+	// backoffCount is the number of backoffs since the last successful poll, each one doubling the backoff period
+	backoffCount int
+
+	// consecutiveFailures is the number of failed polls since the last successful one
+	consecutiveFailures int
+	circuit             CircuitState
+	circuitOpenedAt     time.Time
+
+	// pollWindowStart is when the current poll budget window started, pollWindowRequests the number
+	// of polling requests sent to the backend since
+	pollWindowStart    time.Time
+	pollWindowRequests int
+
+	// latency is the exponentially-weighted moving average of fetchBlock latency
+	latency time.Duration
+	// unreliability is the exponentially-weighted moving average of the failed or diverging polls,
+	// the complement of the reliability score
+	unreliability float64
+	// unreliabilityUpdatedAt is when the unreliability was last recorded, the base of its time decay
+	unreliabilityUpdatedAt time.Time
+
+	// unavailable is set when the backend can't be polled, and cleared once it recovers
+	unavailable bool
+	// stableCycles is the number of consecutive cycles the backend was successfully polled in and in agreement
+	// with the consensus. It is the basis for promoting a backend from warm-up and probation to full voting
+	stableCycles int
+	// polled is set by a successful poll, and cleared by the next cycle counting it as stable
+	polled bool
+	// warmupCycles is the number of stable cycles a recovered backend needs before voting in the consensus,
+	// cleared once reached
+	warmupCycles int
+	// probationCycles is the number of stable cycles a backend that broke the consensus needs before voting again,
+	// cleared once reached
+	probationCycles int
+
+	// forkCycles is the number of consecutive cycles the backend was part of a minority hash cluster
+	forkCycles int
+	// forked is set when the backend is considered to be on a fork, and excluded from the consensus
+	forked bool
+
+	// inconsistentHead is set when the latest block of the backend regressed further than allowed,
+	// and cleared once it reports a consistent latest block again
+	inconsistentHead bool
+
+	// outlier is set when the latest block number of the backend is a statistical outlier of the group,
+	// and cleared once it is back within the outlier sensitivity
+	outlier bool
+
+	// clockSkewed is set when the latest block of the backend is dated beyond the clock skew tolerance,
+	// and cleared once it reports a plausibly dated latest block again
+	clockSkewed bool
+
+	// slowPolls is the number of consecutive polls over the max latency of the backend
+	slowPolls int
+	// slow is set when the backend was over its max latency for the slow polls, and cleared once a poll is back under
+	slow bool
+
+	// pollsSinceEndpointCheck is the number of polls since the latest block was last fetched twice
+	pollsSinceEndpointCheck int
+	// inconsistentEndpoint is set when two successive fetches returned different hashes at the same height,
+	// and cleared once a later check returns consistent hashes
+	inconsistentEndpoint bool
+}
+
+// recordReliability feeds the outcome of a poll into the reliability score of the backend, after decaying
+// the previous score by the time elapsed when halfLife is set. It must be called with the backend state lock held
+func (bs *backendState) recordReliability(failed bool, halfLife time.Duration) {
+	now := time.Now()
+	sample := 0.0
+	if failed {
+		sample = 1
+	}
+	bs.unreliability = reliabilityEWMAWeight*sample + (1-reliabilityEWMAWeight)*bs.decayedUnreliability(now, halfLife)
+	bs.unreliabilityUpdatedAt = now
+}
+
+// decayedUnreliability returns the unreliability of the backend halved for every halfLife elapsed since
+// it was last recorded, or as recorded without a half-life. It must be called with the backend state lock held
+func (bs *backendState) decayedUnreliability(now time.Time, halfLife time.Duration) float64 {
+	if halfLife <= 0 || bs.unreliabilityUpdatedAt.IsZero() {
+		return bs.unreliability
+	}
+	elapsed := now.Sub(bs.unreliabilityUpdatedAt)
+	if elapsed <= 0 {
+		return bs.unreliability
+	}
+	return bs.unreliability * math.Exp2(-float64(elapsed)/float64(halfLife))
+}
+
+// excludedFromVoting returns true if the state of the backend excludes it from voting in the consensus
+func (bs *backendState) excludedFromVoting() bool {
+	return bs.warmupCycles > 0 || bs.probationCycles > 0 || bs.forked || bs.inconsistentHead || bs.clockSkewed || bs.outlier || bs.inconsistentEndpoint || bs.slow
 }
 
 // GetConsensusGroup returns the backend members that are agreeing in a consensus
 func (cp *ConsensusPoller) GetConsensusGroup() []*Backend {
+	cp.refreshLazily()
 	defer cp.consensusGroupMux.Unlock()
 	cp.consensusGroupMux.Lock()
 
-	g := make([]*Backend, len(cp.backendGroup.Backends))
+	g := make([]*Backend, len(cp.consensusGroup))
 	copy(g, cp.consensusGroup)
 
-	return g
+	return g
+}
+
+// GetConsensusGroupSorted returns the backend members that are agreeing in a consensus, the local
+// backends first, then by ascending average fetch latency, the backends without a measured latency last
+func (cp *ConsensusPoller) GetConsensusGroupSorted() []*Backend {
+	g := cp.GetConsensusGroup()
+	latencies := make(map[*Backend]time.Duration, len(g))
+	for _, be := range g {
+		latencies[be] = cp.GetBackendLatency(be)
+	}
+	sort.SliceStable(g, func(i, j int) bool {
+		if g[i].local != g[j].local {
+			return g[i].local
+		}
+		return fasterLatency(latencies[g[i]], latencies[g[j]])
+	})
+	return g
+}
+
+// fasterLatency returns true if the latency a is lower than b, a zero latency being not measured yet,
+// so ranked after any measured one
+func fasterLatency(a, b time.Duration) bool {
+	if a == 0 {
+		return false
+	}
+	return b == 0 || a < b
+}
+
+// preferLocal moves the local backends ahead of the remote ones, keeping the order within each
+func preferLocal(backends []*Backend) []*Backend {
+	sort.SliceStable(backends, func(i, j int) bool {
+		return backends[i].local && !backends[j].local
+	})
+	return backends
+}
+
+// GetConsensusBlockTimestamp returns the timestamp of the consensus block, in seconds since the epoch,
+// or zero when it is not known yet
+func (cp *ConsensusPoller) GetConsensusBlockTimestamp() uint64 {
+	cp.consensusGroupMux.Lock()
+	defer cp.consensusGroupMux.Unlock()
+	return cp.consensusTimestamp
+}
+
+// GetLastReorg returns the last consensus broken event, and false when there was none
+func (cp *ConsensusPoller) GetLastReorg() (ReorgInfo, bool) {
+	cp.consensusGroupMux.Lock()
+	defer cp.consensusGroupMux.Unlock()
+	if cp.lastReorg == nil {
+		return ReorgInfo{}, false
+	}
+	return *cp.lastReorg, true
+}
+
+// GetRoutingGroup returns the consensus group visible to the routing, i.e. the consensus group debounced
+// by WithGroupDebounce
+func (cp *ConsensusPoller) GetRoutingGroup() []*Backend {
+	cp.refreshLazily()
+	defer cp.consensusGroupMux.Unlock()
+	cp.consensusGroupMux.Lock()
+
+	routingGroup := cp.routingGroupLocked()
+	g := make([]*Backend, len(routingGroup))
+	copy(g, routingGroup)
+
+	return g
+}
+
+// routingGroupLocked returns the routing group, the caller must hold consensusGroupMux
+func (cp *ConsensusPoller) routingGroupLocked() []*Backend {
+	if cp.groupDebounceCycles <= 0 {
+		return cp.consensusGroup
+	}
+	return cp.routingGroup
+}
+
+// updateRoutingGroupLocked flips the routing group membership of the backends that were consistently in
+// or out of the consensus group for groupDebounceCycles, the caller must hold consensusGroupMux
+func (cp *ConsensusPoller) updateRoutingGroupLocked(group []*Backend, first bool) {
+	if cp.groupDebounceCycles <= 0 {
+		return
+	}
+	if first {
+		// there is no routing to keep stable before the first commit
+		cp.routingGroup = group
+		cp.routingGroupStreaks = make(map[*Backend]int)
+		return
+	}
+
+	inGroup := make(map[*Backend]bool, len(group))
+	for _, be := range group {
+		inGroup[be] = true
+	}
+	routed := make(map[*Backend]bool, len(cp.routingGroup))
+	for _, be := range cp.routingGroup {
+		routed[be] = true
+	}
+	for _, be := range cp.backendGroup.Backends {
+		if inGroup[be] == routed[be] {
+			delete(cp.routingGroupStreaks, be)
+			continue
+		}
+		cp.routingGroupStreaks[be]++
+		if cp.routingGroupStreaks[be] >= cp.groupDebounceCycles {
+			routed[be] = inGroup[be]
+			delete(cp.routingGroupStreaks, be)
+		}
+	}
+
+	// the routing group follows the order of the consensus group, then keeps the members on their way out
+	routingGroup := make([]*Backend, 0, len(routed))
+	for _, be := range group {
+		if routed[be] {
+			routingGroup = append(routingGroup, be)
+		}
+	}
+	for _, be := range cp.routingGroup {
+		if routed[be] && !inGroup[be] {
+			routingGroup = append(routingGroup, be)
+		}
+	}
+	cp.routingGroup = routingGroup
+}
+
+// GetBackendsAtConsensusHash returns the routing group members whose latest block is the consensus block
+func (cp *ConsensusPoller) GetBackendsAtConsensusHash() []*Backend {
+	cp.refreshLazily()
+	cp.consensusGroupMux.Lock()
+	routingGroup := cp.routingGroupLocked()
+	group := make([]*Backend, len(routingGroup))
+	copy(group, routingGroup)
+	consensusHash := cp.consensusHash
+	cp.consensusGroupMux.Unlock()
+
+	backends := make([]*Backend, 0, len(group))
+	for _, be := range group {
+		if _, blockHash := cp.getBackendState(be); blockHash == consensusHash {
+			backends = append(backends, be)
+		}
+	}
+	return backends
+}
+
+// removeFromConsensusGroup drops the backend from the current consensus group, ahead of the next cycle.
+// The routing group drops it too, without debouncing
+func (cp *ConsensusPoller) removeFromConsensusGroup(be *Backend) {
+	cp.consensusGroupMux.Lock()
+	defer cp.consensusGroupMux.Unlock()
+	cp.consensusGroup = withoutBackend(cp.consensusGroup, be)
+	if cp.groupDebounceCycles > 0 {
+		cp.routingGroup = withoutBackend(cp.routingGroup, be)
+		delete(cp.routingGroupStreaks, be)
+	}
+}
+
+// withoutBackend returns a copy of the backends without be
+func withoutBackend(backends []*Backend, be *Backend) []*Backend {
+	filtered := make([]*Backend, 0, len(backends))
+	for _, member := range backends {
+		if member != be {
+			filtered = append(filtered, member)
+		}
+	}
+	return filtered
+}
+
+// IsInConsensusGroup returns true if the backend with the given name is currently agreeing in the consensus
+func (cp *ConsensusPoller) IsInConsensusGroup(name string) bool {
+	defer cp.consensusGroupMux.Unlock()
+	cp.consensusGroupMux.Lock()
+
+	for _, be := range cp.consensusGroup {
+		if be.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckReady returns nil if the consensus can be routed to, or the sentinel error of the not-ready
+// condition: ErrConsensusNotReady, ErrConsensusGroupEmpty, ErrConsensusDegraded, ErrConsensusStale or ErrConsensusSplitBrain
+func (cp *ConsensusPoller) CheckReady() error {
+	if cp.GetConsensusBlockNumber() == 0 {
+		return ErrConsensusNotReady
+	}
+	if cp.IsConsensusStale() {
+		return ErrConsensusStale
+	}
+	if cp.IsSplitBrain() {
+		return ErrConsensusSplitBrain
+	}
+	cp.consensusGroupMux.Lock()
+	size := len(cp.consensusGroup)
+	cp.consensusGroupMux.Unlock()
+	if size == 0 {
+		return ErrConsensusGroupEmpty
+	}
+	if quorum := cp.quorumSize(); size < quorum {
+		return fmt.Errorf("%w: %d of %d backends", ErrConsensusDegraded, size, quorum)
+	}
+	return nil
+}
+
+// WaitReady blocks until a consensus cycle committed a consensus passing CheckReady, i.e. enough backends
+// agree on a block, or until the context is done. A consensus restored from a snapshot only counts once
+// a cycle confirmed it. On timeout, the returned error wraps the context error and the last not-ready condition
+func (cp *ConsensusPoller) WaitReady(ctx context.Context) error {
+	ticker := time.NewTicker(waitReadyInterval)
+	defer ticker.Stop()
+	for {
+		err := cp.checkWarmedUp()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %v", ctx.Err(), err)
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkWarmedUp is CheckReady, but also requires the consensus to be committed by a cycle of this poller
+func (cp *ConsensusPoller) checkWarmedUp() error {
+	cp.consensusGroupMux.Lock()
+	committed := !cp.committedAt.IsZero()
+	cp.consensusGroupMux.Unlock()
+	if !committed {
+		return ErrConsensusNotReady
+	}
+	return cp.CheckReady()
+}
+
+// rejectRequests returns the not-ready condition of CheckReady the requests must be rejected for, i.e. the group
+// is split brain, which always fails closed, or the consensus is not ready and the poller fails closed, else nil
+func (cp *ConsensusPoller) rejectRequests() error {
+	err := cp.CheckReady()
+	if errors.Is(err, ErrConsensusSplitBrain) || (err != nil && cp.failMode == FailClosed) {
+		return err
+	}
+	return nil
+}
+
+// IsSplitBrain returns true while several hash clusters of the group meet the quorum at the same height
+func (cp *ConsensusPoller) IsSplitBrain() bool {
+	cp.consensusGroupMux.Lock()
+	defer cp.consensusGroupMux.Unlock()
+	return cp.splitBrain
+}
+
+// GetConsensusHealth returns the health of the consensus as of the last cycle: healthy when the whole group
+// agrees, degraded when some backends are out of the consensus group, and no consensus when there is none to route to
+func (cp *ConsensusPoller) GetConsensusHealth() ConsensusHealth {
+	cp.refreshLazily()
+	cp.consensusGroupMux.Lock()
+	defer cp.consensusGroupMux.Unlock()
+	return cp.health
+}
+
+// updateConsensusHealth computes the health of the consensus from its committed state, logging and recording
+// the transitions. Only the members of the consensus group still eligible count, as a cycle without a proposal,
+// i.e. with every backend banned, leaves the committed group in place
+func (cp *ConsensusPoller) updateConsensusHealth() {
+	blockNumber := cp.tracker.GetConsensusBlockNumber()
+	cp.consensusGroupMux.Lock()
+	group := cp.consensusGroup
+	splitBrain := cp.splitBrain
+	cp.consensusGroupMux.Unlock()
+
+	eligible := 0
+	for _, be := range group {
+		if filtered, _ := cp.isFiltered(be); !filtered {
+			eligible++
+		}
+	}
+	health := ConsensusHealthy
+	switch {
+	case blockNumber == 0 || eligible == 0 || splitBrain:
+		health = NoConsensus
+	case eligible < len(cp.backendGroup.Backends):
+		health = ConsensusDegraded
+	}
+
+	cp.consensusGroupMux.Lock()
+	previous := cp.health
+	cp.health = health
+	cp.consensusGroupMux.Unlock()
+
+	RecordGroupConsensusHealth(cp.backendGroup, health)
+	if health != previous {
+		cp.logger.Info("consensus health changed", "group", cp.backendGroup.Name, "health", health.String(), "previous", previous.String(), "eligible", eligible, "backends", len(cp.backendGroup.Backends))
+	}
+}
+
+// setSplitBrain flags or clears the split brain of the group, logging and recording the transitions
+func (cp *ConsensusPoller) setSplitBrain(splitBrain bool, blockNumber hexutil.Uint64, hashes []string) {
+	cp.consensusGroupMux.Lock()
+	changed := cp.splitBrain != splitBrain
+	cp.splitBrain = splitBrain
+	cp.consensusGroupMux.Unlock()
+	if !changed {
+		return
+	}
+	RecordGroupConsensusSplitBrain(cp.backendGroup, splitBrain)
+	if splitBrain {
+		cp.logger.Error("consensus split brain, several hash clusters meet the quorum, failing closed", "group", cp.backendGroup.Name, "blockNum", blockNumber, "hashes", hashes)
+	} else {
+		cp.logger.Info("consensus split brain resolved", "group", cp.backendGroup.Name, "blockNum", blockNumber)
+	}
+}
+
+// capBlockNumbers caps the eth_blockNumber results to the consensus block number,
+// so clients don't see blocks that are not agreed on yet
+func (cp *ConsensusPoller) capBlockNumbers(rpcReqs []*RPCReq, rpcRes []*RPCRes) {
+	if !cp.capBlockNumber {
+		return
+	}
+	consensusBlockNumber := cp.GetConsensusBlockNumber()
+	if consensusBlockNumber == 0 {
+		return
+	}
+	for i, req := range rpcReqs {
+		if req.Method != "eth_blockNumber" || rpcRes[i].IsError() {
+			continue
+		}
+		blockNumber, err := parseQuantity(rpcRes[i].Result)
+		if err != nil {
+			continue
+		}
+		if blockNumber > consensusBlockNumber {
+			rpcRes[i].Result = consensusBlockNumber.String()
+		}
+	}
+}
+
+// rewriteBlockTags returns the requests with the block tag of eth_getBlockByNumber rewritten to the consensus
+// block where the tag refers to the head, so clients don't get blocks that are not agreed on yet, and with the
+// range of eth_getLogs clamped to the consensus block, see WithLogsRangeClamp. The rewritten requests are copies,
+// the other ones are returned as is. The eth_getLogs requests of a range past the consensus block are not forwarded:
+// they are answered without logs in answered, at their index in rpcReqs, which is nil when every request is forwarded
+func (cp *ConsensusPoller) rewriteBlockTags(rpcReqs []*RPCReq) (forwarded []*RPCReq, answered []*RPCRes) {
+	if !cp.capBlockNumber && !cp.clampLogsRange {
+		return rpcReqs, nil
+	}
+	consensusBlockNumber := cp.GetConsensusBlockNumber()
+	if consensusBlockNumber == 0 {
+		return rpcReqs, nil
+	}
+
+	rewritten := rpcReqs
+	copied := false
+	for i, req := range rpcReqs {
+		var reqParams json.RawMessage
+		var ok, empty bool
+		switch {
+		case req.Method == "eth_getBlockByNumber" && cp.capBlockNumber:
+			reqParams, ok = rewriteBlockNumberParams(req.Params, consensusBlockNumber)
+		case req.Method == "eth_getLogs" && cp.clampLogsRange:
+			reqParams, ok, empty = clampLogsRangeParams(req.Params, consensusBlockNumber)
+		}
+		if empty {
+			if answered == nil {
+				answered = make([]*RPCRes, len(rpcReqs))
+			}
+			answered[i] = NewRPCRes(req.ID, []interface{}{})
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if !copied {
+			rewritten = make([]*RPCReq, len(rpcReqs))
+			copy(rewritten, rpcReqs)
+			copied = true
+		}
+		rewrittenReq := *req
+		rewrittenReq.Params = reqParams
+		rewritten[i] = &rewrittenReq
+	}
+	if answered == nil {
+		return rewritten, nil
+	}
+
+	forwarded = make([]*RPCReq, 0, len(rewritten))
+	for i, req := range rewritten {
+		if answered[i] == nil {
+			forwarded = append(forwarded, req)
+		}
+	}
+	return forwarded, answered
+}
+
+// rewriteBlockNumberParams returns the eth_getBlockByNumber params with the block tag rewritten to the
+// consensus block, and false when there is nothing to rewrite
+func rewriteBlockNumberParams(rawParams json.RawMessage, consensusBlockNumber hexutil.Uint64) (json.RawMessage, bool) {
+	var params []json.RawMessage
+	if err := json.Unmarshal(rawParams, &params); err != nil || len(params) == 0 {
+		return nil, false
+	}
+	var tag string
+	if err := json.Unmarshal(params[0], &tag); err != nil {
+		return nil, false
+	}
+	block, ok := rewriteBlockTag(tag, consensusBlockNumber)
+	if !ok {
+		return nil, false
+	}
+	params[0], _ = json.Marshal(block)
+	reqParams, err := json.Marshal(params)
+	if err != nil {
+		return nil, false
+	}
+	return reqParams, true
+}
+
+// clampLogsRangeParams returns the eth_getLogs params with the fromBlock and the toBlock clamped to the consensus
+// block, and false when they are within it already. A missing block defaults to latest, and the filters by block
+// hash are left as is. empty is true when the range starts past the consensus block, and has no agreed logs
+func clampLogsRangeParams(rawParams json.RawMessage, consensusBlockNumber hexutil.Uint64) (reqParams json.RawMessage, clamped bool, empty bool) {
+	var params []map[string]json.RawMessage
+	if err := json.Unmarshal(rawParams, &params); err != nil || len(params) == 0 || params[0] == nil {
+		return nil, false, false
+	}
+	filter := params[0]
+	if _, ok := filter["blockHash"]; ok {
+		return nil, false, false
+	}
+	for _, field := range []string{"fromBlock", "toBlock"} {
+		block := "latest"
+		if raw, ok := filter[field]; ok {
+			if err := json.Unmarshal(raw, &block); err != nil {
+				return nil, false, false
+			}
+		}
+		switch block {
+		case "latest", "pending":
+		case "earliest", "safe", "finalized":
+			// these trail the head, they are already agreed on by the time the consensus reaches them
+			continue
+		default:
+			blockNumber, err := hexutil.DecodeUint64(block)
+			if err != nil {
+				return nil, false, false
+			}
+			if hexutil.Uint64(blockNumber) <= consensusBlockNumber {
+				continue
+			}
+			if field == "fromBlock" {
+				return nil, false, true
+			}
+		}
+		filter[field], _ = json.Marshal(consensusBlockNumber.String())
+		clamped = true
+	}
+	if !clamped {
+		return nil, false, false
+	}
+	reqParams, err := json.Marshal(params)
+	if err != nil {
+		return nil, false, false
+	}
+	return reqParams, true, false
+}
+
+func rewriteBlockTag(tag string, consensusBlockNumber hexutil.Uint64) (string, bool) {
+	switch tag {
+	case "latest":
+		return consensusBlockNumber.String(), true
+	case "earliest":
+		// the genesis block doesn't depend on the consensus
+		return tag, false
+	case "pending":
+		// the pending block is built by the backend on top of its own head, it has no agreed counterpart
+		return tag, false
+	case "safe", "finalized":
+		// both trail the head, and are already agreed on by the time the consensus reaches them
+		return tag, false
+	default:
+		// block numbers and hashes
+		return tag, false
+	}
+}
+
+// GetFastestConsensusBackend returns the consensus group member with the lowest average fetch latency,
+// or nil if there is no consensus group. A member without a measured latency is only picked when no
+// member has one
+func (cp *ConsensusPoller) GetFastestConsensusBackend() *Backend {
+	cp.consensusGroupMux.Lock()
+	group := make([]*Backend, len(cp.consensusGroup))
+	copy(group, cp.consensusGroup)
+	cp.consensusGroupMux.Unlock()
+
+	var fastest *Backend
+	var fastestLatency time.Duration
+	for _, be := range group {
+		latency := cp.GetBackendLatency(be)
+		if fastest == nil || fasterLatency(latency, fastestLatency) {
+			fastest = be
+			fastestLatency = latency
+		}
+	}
+	return fastest
+}
+
+// GetBackendLatency returns the average latency observed fetching blocks from the backend, zero
+// until a first fetch is measured
+func (cp *ConsensusPoller) GetBackendLatency(be *Backend) time.Duration {
+	bs := cp.backendState[be]
+	defer bs.backendStateMux.Unlock()
+	bs.backendStateMux.Lock()
+	return bs.latency
+}
+
+// GetBackendReliability returns the reliability score of the backend, from 1 for a backend that never
+// failed or diverged from the group, down to 0, based on its recent poll history. With a reliability
+// half-life, the score recovers over time after a bad period, see WithReliabilityHalfLife
+func (cp *ConsensusPoller) GetBackendReliability(be *Backend) float64 {
+	bs := cp.backendState[be]
+	defer bs.backendStateMux.Unlock()
+	bs.backendStateMux.Lock()
+	return 1 - bs.decayedUnreliability(time.Now(), cp.reliabilityHalfLife)
+}
+
+// GetBackendHealth returns the health of the backend with the given name, combining its online status, its ban,
+// its consensus group membership and its reliability score, the first matching of offline, banned, degraded
+func (cp *ConsensusPoller) GetBackendHealth(name string) (BackendHealth, error) {
+	be := cp.backendGroup.getBackend(name)
+	if be == nil {
+		return BackendOffline, fmt.Errorf("unknown backend %s in group %s", name, cp.backendGroup.Name)
+	}
+	if !be.Online() || cp.isCircuitOpen(be) {
+		return BackendOffline, nil
+	}
+	if cp.isBanned(be) {
+		return BackendBanned, nil
+	}
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	unavailable := bs.unavailable
+	bs.backendStateMux.Unlock()
+	if unavailable || cp.isExcludedFromVoting(be) || !cp.IsInConsensusGroup(name) || cp.GetBackendReliability(be) < minHealthyReliability {
+		return BackendDegraded, nil
+	}
+	return BackendHealthy, nil
+}
+
+// recordReliability feeds the outcome of a poll into the reliability score of the backend
+func (cp *ConsensusPoller) recordReliability(be *Backend, failed bool) {
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	bs.recordReliability(failed, cp.reliabilityHalfLife)
+	bs.backendStateMux.Unlock()
+}
+
+// BackendConsensusInfo is a point-in-time view of the consensus state of a backend
+type BackendConsensusInfo struct {
+	LatestBlockNumber hexutil.Uint64
+	LatestBlockHash   string
+	LastUpdate        time.Time
+	BannedUntil       time.Time
+	Latency           time.Duration
+	Unavailable       bool
+	ExcludedFromVote  bool
+	StableCycles      int
+	Circuit           CircuitState
+	Reliability       float64
+}
+
+// SnapshotBackendStates returns the state of every backend, keyed by backend name. All the state
+// locks are held together, so the states are consistent with each other
+func (cp *ConsensusPoller) SnapshotBackendStates() map[string]BackendConsensusInfo {
+	for _, be := range cp.backendGroup.Backends {
+		cp.backendState[be].backendStateMux.Lock()
+	}
+	defer func() {
+		for _, be := range cp.backendGroup.Backends {
+			cp.backendState[be].backendStateMux.Unlock()
+		}
+	}()
+
+	now := time.Now()
+	states := make(map[string]BackendConsensusInfo, len(cp.backendGroup.Backends))
+	for _, be := range cp.backendGroup.Backends {
+		bs := cp.backendState[be]
+		states[be.Name] = BackendConsensusInfo{
+			LatestBlockNumber: bs.latestBlockNumber,
+			LatestBlockHash:   bs.latestBlockHash,
+			LastUpdate:        bs.lastUpdate,
+			BannedUntil:       bs.bannedUntil,
+			Latency:           bs.latency,
+			Unavailable:       bs.unavailable,
+			ExcludedFromVote:  !be.votesInConsensus() || bs.excludedFromVoting(),
+			StableCycles:      bs.stableCycles,
+			Circuit:           bs.circuit,
+			Reliability:       1 - bs.decayedUnreliability(now, cp.reliabilityHalfLife),
+		}
+	}
+	return states
+}
+
+// GetLowestBlock returns the lowest latest block observed across the online backends,
+// and the names of the backends at that block
+func (cp *ConsensusPoller) GetLowestBlock() (hexutil.Uint64, []string) {
+	return cp.getBlockBound(func(a, b hexutil.Uint64) bool { return a < b })
+}
+
+// GetHighestBlock returns the highest latest block observed across the online backends,
+// and the names of the backends at that block
+func (cp *ConsensusPoller) GetHighestBlock() (hexutil.Uint64, []string) {
+	return cp.getBlockBound(func(a, b hexutil.Uint64) bool { return a > b })
+}
+
+func (cp *ConsensusPoller) getBlockBound(better func(a, b hexutil.Uint64) bool) (hexutil.Uint64, []string) {
+	var bound hexutil.Uint64
+	names := make([]string, 0)
+	for _, be := range cp.backendGroup.Backends {
+		bs := cp.backendState[be]
+		bs.backendStateMux.Lock()
+		blockNumber, unavailable := bs.latestBlockNumber, bs.unavailable
+		bs.backendStateMux.Unlock()
+
+		if blockNumber == 0 || unavailable || !be.Online() {
+			continue
+		}
+		if len(names) == 0 || better(blockNumber, bound) {
+			bound = blockNumber
+			names = names[:0]
+		}
+		if blockNumber == bound {
+			names = append(names, be.Name)
+		}
+	}
+	return bound, names
+}
+
+// recordConsensusHistory appends the entry to the consensus history, evicting the oldest entry once full.
+// It must be called with the consensus group lock held
+func (cp *ConsensusPoller) recordConsensusHistory(entry ConsensusEntry) {
+	if cp.consensusHistorySize <= 0 {
+		return
+	}
+	if len(cp.consensusHistory) < cp.consensusHistorySize {
+		cp.consensusHistory = append(cp.consensusHistory, entry)
+		return
+	}
+	cp.consensusHistory[cp.consensusHistoryStart] = entry
+	cp.consensusHistoryStart = (cp.consensusHistoryStart + 1) % len(cp.consensusHistory)
+}
+
+// GetRecentConsensus returns up to the n last consensus blocks, oldest first. A block number appears
+// more than once when its hash changed, i.e. on a reorg, letting a cache invalidator find the
+// cached blocks that changed hashes
+func (cp *ConsensusPoller) GetRecentConsensus(n int) []ConsensusEntry {
+	cp.consensusGroupMux.Lock()
+	defer cp.consensusGroupMux.Unlock()
+	size := len(cp.consensusHistory)
+	if n > size {
+		n = size
+	}
+	if n <= 0 {
+		return nil
+	}
+	entries := make([]ConsensusEntry, 0, n)
+	for i := size - n; i < size; i++ {
+		entries = append(entries, cp.consensusHistory[(cp.consensusHistoryStart+i)%size])
+	}
+	return entries
+}
+
+// GetBackendBlockHistory returns up to the n last polled latest blocks of the backend, oldest first,
+// or nil for an unknown backend
+func (cp *ConsensusPoller) GetBackendBlockHistory(name string, n int) []BackendBlockEntry {
+	be := cp.backendGroup.getBackend(name)
+	if be == nil {
+		return nil
+	}
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	defer bs.backendStateMux.Unlock()
+	size := len(bs.blockHistory)
+	if n > size {
+		n = size
+	}
+	if n <= 0 {
+		return nil
+	}
+	entries := make([]BackendBlockEntry, 0, n)
+	for i := size - n; i < size; i++ {
+		entries = append(entries, bs.blockHistory[(bs.blockHistoryStart+i)%size])
+	}
+	return entries
+}
+
+// recordBlockHistory appends the entry to the block history of the backend, it must be called
+// with the backend state lock held
+func (bs *backendState) recordBlockHistory(entry BackendBlockEntry, size int) {
+	if size <= 0 {
+		return
+	}
+	if len(bs.blockHistory) < size {
+		bs.blockHistory = append(bs.blockHistory, entry)
+		return
+	}
+	bs.blockHistory[bs.blockHistoryStart] = entry
+	bs.blockHistoryStart = (bs.blockHistoryStart + 1) % len(bs.blockHistory)
+}
+
+// GetConsensusBlockNumber returns the agreed block number in a consensus
+func (ct *ConsensusPoller) GetConsensusBlockNumber() hexutil.Uint64 {
+	ct.refreshLazily()
+	return ct.tracker.GetConsensusBlockNumber()
+}
+
+// GetFreshConsensusBlockNumber is like GetConsensusBlockNumber, but also returns ErrConsensusStale
+// along with the last value when the consensus is stale, see WithStaleThreshold
+func (cp *ConsensusPoller) GetFreshConsensusBlockNumber() (hexutil.Uint64, error) {
+	blockNumber := cp.GetConsensusBlockNumber()
+	if cp.IsConsensusStale() {
+		return blockNumber, ErrConsensusStale
+	}
+	return blockNumber, nil
+}
+
+// GetConsensusConfirmations returns the number of cycles the consensus block was held since it was reached
+func (cp *ConsensusPoller) GetConsensusConfirmations() uint64 {
+	return cp.tracker.GetConsensusConfirmations()
+}
+
+// IsConsensusBlockConfirmed returns true if the consensus block was held for the confirmation cycles, and
+// false while it is speculative, i.e. freshly reached and not held yet, see WithConfirmationCycles
+func (cp *ConsensusPoller) IsConsensusBlockConfirmed() bool {
+	return cp.GetConsensusConfirmations() >= cp.confirmationCycles
+}
+
+// GetConfirmedConsensusBlockNumber returns the last consensus block that was held for the confirmation cycles,
+// for the reads that can't be served a speculative block
+func (cp *ConsensusPoller) GetConfirmedConsensusBlockNumber() hexutil.Uint64 {
+	cp.consensusGroupMux.Lock()
+	defer cp.consensusGroupMux.Unlock()
+	return cp.confirmedBlockNumber
+}
+
+// IsConsensusStale returns true if a stale threshold is set and no cycle committed the consensus within it,
+// including when no cycle committed it at all yet
+func (cp *ConsensusPoller) IsConsensusStale() bool {
+	if cp.staleThreshold == 0 {
+		return false
+	}
+	cp.consensusGroupMux.Lock()
+	committedAt := cp.committedAt
+	cp.consensusGroupMux.Unlock()
+	return committedAt.IsZero() || time.Since(committedAt) > cp.staleThreshold
+}
+
+func (cp *ConsensusPoller) Shutdown() {
+	cp.asyncHandler.Shutdown()
+	cp.cancelFunc()
+
+	cp.observersMux.Lock()
+	defer cp.observersMux.Unlock()
+	if !cp.shutdown {
+		cp.shutdown = true
+		for _, ch := range cp.blockSubscribers {
+			close(ch)
+		}
+		cp.blockSubscribers = nil
+	}
+}
+
+// BlockComparison holds the hash of a block on each of two compared backends
+type BlockComparison struct {
+	Number hexutil.Uint64
+	HashA  string
+	HashB  string
+}
+
+// ComparisonResult is the outcome of CompareBackends
+type ComparisonResult struct {
+	// Blocks are the compared blocks, from the lowest latest block of the two backends down to
+	// their first common block, when found within DefaultCompareDepth blocks
+	Blocks []BlockComparison
+	// Diverged is set when the backends disagree on their lowest latest block
+	Diverged bool
+	// DivergedAt is the first block where the backends disagree, when they diverged
+	DivergedAt hexutil.Uint64
+}
+
+// CompareBackends is an on-demand diagnostic fetching the same blocks from two backends of the group,
+// walking back from their lowest latest block to find where they first diverge
+func (cp *ConsensusPoller) CompareBackends(ctx context.Context, nameA string, nameB string) (ComparisonResult, error) {
+	var result ComparisonResult
+	backends := make([]*Backend, 0, 2)
+	for _, name := range []string{nameA, nameB} {
+		be := cp.backendGroup.getBackend(name)
+		if be == nil {
+			return result, fmt.Errorf("unknown backend %s in group %s", name, cp.backendGroup.Name)
+		}
+		backends = append(backends, be)
+	}
+
+	fetch := func(block string) ([2]blockResult, error) {
+		var results [2]blockResult
+		err := cp.runConcurrently(ctx, len(backends), func(i int) {
+			res := &results[i]
+			res.number, res.hash, _, res.err = cp.fetchBlock(ctx, backends[i], block)
+		})
+		if err != nil {
+			return results, err
+		}
+		for i, res := range results {
+			if res.err != nil {
+				return results, wrapErr(res.err, fmt.Sprintf("error fetching block %s from backend %s", block, backends[i].Name))
+			}
+		}
+		return results, nil
+	}
+
+	latest, err := fetch("latest")
+	if err != nil {
+		return result, err
+	}
+	block := latest[0].number
+	if latest[1].number < block {
+		block = latest[1].number
+	}
+
+	for depth := 0; depth < DefaultCompareDepth; depth++ {
+		blocks, err := fetch(block.String())
+		if err != nil {
+			return result, err
+		}
+		result.Blocks = append(result.Blocks, BlockComparison{
+			Number: block,
+			HashA:  blocks[0].hash,
+			HashB:  blocks[1].hash,
+		})
+		if blocks[0].hash == blocks[1].hash {
+			break
+		}
+		result.Diverged = true
+		result.DivergedAt = block
+		if block == 0 {
+			break
+		}
+		block--
+	}
+	return result, nil
+}
+
+// refreshLazily polls the backends and recomputes the group consensus in lazy mode, when the last refresh
+// is older than the lazy TTL. The concurrent queries wait for the same refresh
+func (cp *ConsensusPoller) refreshLazily() {
+	if cp.lazyTTL <= 0 {
+		return
+	}
+	cp.lazyMux.Lock()
+	defer cp.lazyMux.Unlock()
+	if time.Since(cp.lazyRefreshedAt) < cp.lazyTTL || cp.ctx.Err() != nil {
+		return
+	}
+
+	cp.UpdateBackends(cp.ctx)
+	cp.UpdateBackendGroupConsensus(cp.ctx)
+	cp.lazyRefreshedAt = time.Now()
+}
+
+// TriggerRefresh requests an immediate refresh of the backends and the group consensus, out of the
+// poller interval, i.e. on a cache miss for the latest block. The calls within the refresh debounce
+// window are coalesced in a single refresh
+func (cp *ConsensusPoller) TriggerRefresh() {
+	cp.refreshOnce.Do(func() {
+		go cp.refreshLoop()
+	})
+	select {
+	case cp.refreshC <- struct{}{}:
+	default:
+		// a refresh is already pending
+	}
+}
+
+func (cp *ConsensusPoller) refreshLoop() {
+	for {
+		select {
+		case <-cp.refreshC:
+		case <-cp.ctx.Done():
+			return
+		}
+
+		timer := time.NewTimer(cp.refreshDebounce)
+		select {
+		case <-timer.C:
+		case <-cp.ctx.Done():
+			timer.Stop()
+			return
+		}
+		// the triggers received while waiting are served by this refresh
+		select {
+		case <-cp.refreshC:
+		default:
+		}
+
+		// the refresh polls the same rotating sample as the timed cycles, and its consensus resolution is
+		// serialized with theirs
+		cp.UpdateBackends(cp.ctx)
+		cp.UpdateBackendGroupConsensus(cp.ctx)
+	}
+}
+
+// OnCycle registers a callback receiving the result of every group consensus cycle, committed or not.
+// Callbacks are dispatched in order from a separate goroutine, so a slow callback never blocks
+// the poller; results are dropped while its queue is full
+func (cp *ConsensusPoller) OnCycle(fn func(CycleResult)) {
+	ch := make(chan CycleResult, observerBufferSize)
+	cp.observersMux.Lock()
+	cp.observers = append(cp.observers, ch)
+	cp.observersMux.Unlock()
+
+	go func() {
+		for {
+			select {
+			case result := <-ch:
+				fn(result)
+			case <-cp.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// uncommittedCycleResult describes a cycle committing no proposal by the consensus left in place
+func (cp *ConsensusPoller) uncommittedCycleResult() CycleResult {
+	cp.consensusGroupMux.Lock()
+	defer cp.consensusGroupMux.Unlock()
+	consensusBackendsNames := make([]string, 0, len(cp.consensusGroup))
+	for _, be := range cp.consensusGroup {
+		consensusBackendsNames = append(consensusBackendsNames, be.Name)
+	}
+	return CycleResult{
+		BlockNumber:    cp.tracker.GetConsensusBlockNumber(),
+		BlockHash:      cp.consensusHash,
+		ConsensusGroup: consensusBackendsNames,
+	}
+}
+
+func (cp *ConsensusPoller) notifyObservers(result CycleResult) {
+	cp.observersMux.Lock()
+	defer cp.observersMux.Unlock()
+	for _, ch := range cp.observers {
+		select {
+		case ch <- result:
+		default:
+			cp.logger.Warn("dropping consensus cycle result for a slow observer", "group", cp.backendGroup.Name)
+		}
+	}
+}
+
+// allBackendsDown returns true if none of the backends of the group can take part in the consensus
+func (cp *ConsensusPoller) allBackendsDown() bool {
+	for _, be := range cp.backendGroup.Backends {
+		if filtered, _ := cp.isFiltered(be); !filtered {
+			return false
+		}
+	}
+	return true
+}
+
+// emitEvent queues the event for the webhook, unless an event of the same type was sent
+// less than webhookMinInterval ago, or the queue is full
+func (cp *ConsensusPoller) emitEvent(event ConsensusEvent) {
+	now := time.Now()
+	cp.webhookMux.Lock()
+	if last, ok := cp.webhookLastSent[event.Event]; ok && now.Sub(last) < cp.webhookMinInterval {
+		cp.webhookMux.Unlock()
+		cp.logger.Debug("rate limiting consensus webhook event", "event", event.Event)
+		return
+	}
+	cp.webhookLastSent[event.Event] = now
+	cp.webhookMux.Unlock()
+
+	event.Group = cp.backendGroup.Name
+	event.Time = now
+	if event.ConsensusGroup == nil {
+		event.ConsensusGroup = []string{}
+	}
+	select {
+	case cp.webhookEvents <- event:
+	default:
+		cp.logger.Warn("dropping consensus webhook event", "event", event.Event)
+	}
+}
+
+// dispatchWebhook posts the queued events to the webhook until the poller shuts down
+func (cp *ConsensusPoller) dispatchWebhook() {
+	for {
+		select {
+		case event := <-cp.webhookEvents:
+			if err := cp.postWebhook(event); err != nil {
+				cp.logger.Warn("error posting consensus webhook event", "event", event.Event, "err", err)
+			}
+		case <-cp.ctx.Done():
+			return
+		}
+	}
+}
+
+func (cp *ConsensusPoller) postWebhook(event ConsensusEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(cp.ctx, http.MethodPost, cp.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return redactURLError(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := cp.webhookClient.Do(req)
+	if err != nil {
+		return redactURLError(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected webhook response status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// SubscribeConsensusBlock returns a channel receiving the new consensus block number whenever it changes.
+// A slow subscriber loses the oldest queued block numbers, never the latest one. The channel is closed
+// when the poller shuts down
+func (cp *ConsensusPoller) SubscribeConsensusBlock() <-chan hexutil.Uint64 {
+	ch := make(chan hexutil.Uint64, blockSubscriberBufferSize)
+	cp.observersMux.Lock()
+	defer cp.observersMux.Unlock()
+	if cp.shutdown {
+		close(ch)
+		return ch
+	}
+	cp.blockSubscribers = append(cp.blockSubscribers, ch)
+	return ch
+}
+
+func (cp *ConsensusPoller) notifyBlockSubscribers(blockNumber hexutil.Uint64) {
+	cp.observersMux.Lock()
+	defer cp.observersMux.Unlock()
+	for _, ch := range cp.blockSubscribers {
+		for {
+			select {
+			case ch <- blockNumber:
+			default:
+				// drop the oldest block number to make room for the latest one
+				select {
+				case <-ch:
+				default:
+				}
+				continue
+			}
+			break
+		}
+	}
+}
+
+// Poller is the minimal set of operations needed to drive the consensus polling,
+// allowing custom schedulers to depend on it instead of the concrete ConsensusPoller
+type Poller interface {
+	UpdateBackend(ctx context.Context, be *Backend)
+	UpdateBackendGroupConsensus(ctx context.Context)
+}
+
+var _ Poller = (*ConsensusPoller)(nil)
+
+// ConsensusAsyncHandler controls the asynchronous polling mechanism, interval and shutdown
+type ConsensusAsyncHandler interface {
+	Init()
+	Shutdown()
+}
+
+// NoopAsyncHandler allows fine control updating the consensus
+type NoopAsyncHandler struct{}
+
+func NewNoopAsyncHandler() ConsensusAsyncHandler {
+	log.Warn("using NewNoopAsyncHandler")
+	return &NoopAsyncHandler{}
+}
+func (ah *NoopAsyncHandler) Init()     {}
+func (ah *NoopAsyncHandler) Shutdown() {}
+
+// PollerAsyncHandler asynchronously updates each individual backend and the group consensus
+type PollerAsyncHandler struct {
+	ctx context.Context
+	cp  *ConsensusPoller
+}
+
+func NewPollerAsyncHandler(ctx context.Context, cp *ConsensusPoller) ConsensusAsyncHandler {
+	return &PollerAsyncHandler{
+		ctx: ctx,
+		cp:  cp,
+	}
+}
+func (ah *PollerAsyncHandler) Init() {
+	if ah.cp.pollSampleSize > 0 {
+		// poll a rotating sample of the backends every cycle
+		go func() {
+			for {
+				timer := time.NewTimer(ah.cp.pollInterval())
+				ah.cp.UpdateBackends(ah.ctx)
+
+				select {
+				case <-timer.C:
+				case <-ah.ctx.Done():
+					timer.Stop()
+					return
+				}
+			}
+		}()
+	} else {
+		// create the individual backend pollers
+		for _, be := range ah.cp.backendGroup.Backends {
+			go func(be *Backend) {
+				for {
+					timer := time.NewTimer(ah.cp.pollInterval())
+					ah.cp.UpdateBackend(ah.ctx, be)
+
+					select {
+					case <-timer.C:
+					case <-ah.ctx.Done():
+						timer.Stop()
+						return
+					}
+				}
+			}(be)
+		}
+	}
+
+	// create the group consensus poller
+	go func() {
+		for {
+			timer := time.NewTimer(ah.cp.pollInterval())
+			ah.cp.UpdateBackendGroupConsensus(ah.ctx)
+
+			select {
+			case <-timer.C:
+			case <-ah.ctx.Done():
+				timer.Stop()
+				return
+			}
+		}
+	}()
+}
+func (ah *PollerAsyncHandler) Shutdown() {
+	ah.cp.cancelFunc()
+}
+
+type ConsensusOpt func(cp *ConsensusPoller)
+
+func WithTracker(tracker ConsensusTracker) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.tracker = tracker
+	}
+}
+
+func WithAsyncHandler(asyncHandler ConsensusAsyncHandler) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.asyncHandler = asyncHandler
+	}
+}
+
+// WithPollerHTTPClient polls the backends through a dedicated HTTP client instead of the one
+// used to serve requests, i.e. to tune keep-alive and idle connections for polling.
+// Note the client replaces the backend transport, including any custom TLS config.
+func WithPollerHTTPClient(client *http.Client) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.client = client
+	}
+}
+
+// WithPollerHTTP2 polls the backends over HTTP/2 when they negotiate it over TLS, multiplexing the
+// concurrent polls of a backend over a single connection. Plain http:// backends keep polling over HTTP/1.1
+func WithPollerHTTP2() ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.pollHTTP2 = true
+	}
+}
+
+// WithPollRecorder records every poll of the backends, on top of the HTTP client polling them, so the
+// recording can be replayed with WithPollReplay
+func WithPollRecorder(recorder *PollRecorder) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.pollRecorder = recorder
+	}
+}
+
+// WithPollReplay polls the backends from a recording instead of over the network, each backend serving
+// the polls recorded under its name. It is meant to reproduce an incident in a test
+func WithPollReplay(replay *ReplayFetcher) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.pollReplay = replay
+	}
+}
+
+// WithConsensusMode selects the algorithm used to resolve the group consensus
+func WithConsensusMode(mode ConsensusMode) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.mode = mode
+	}
+}
+
+// WithReferenceEndpoint checks the consensus against a trusted endpoint, i.e. a known-good provider, polled
+// every cycle but never routed to. The divergence is only alerted on, through a log and a metric, when the
+// consensus is more than maxDivergence blocks away from the reference or disagrees on the consensus block hash
+func WithReferenceEndpoint(rpcURL string, maxDivergence uint64) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.referenceURL = rpcURL
+		cp.referenceMaxDivergence = maxDivergence
+	}
+}
+
+// WithWebhook posts a JSON ConsensusEvent to the URL on the significant consensus events: a reorg, the
+// consensus group shrinking, or all the backends down. The posts are asynchronous, and each event type is
+// posted at most once per DefaultWebhookMinInterval; a failed post is only logged
+func WithWebhook(url string) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.webhookURL = url
+	}
+}
+
+// WithShadowBackends polls the given backends every cycle and reports, through metrics, how far they are
+// from the consensus and whether they disagree on the consensus block hash. Shadow backends never vote
+// nor serve requests, so they can be trialed before being added to the group
+func WithShadowBackends(backends ...*Backend) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.shadowBackends = append(cp.shadowBackends, backends...)
+	}
+}
+
+// WithAdaptivePolling derives the poll interval from the observed block time, to poll slightly faster than
+// the chain produces blocks. The interval is bounded by WithPollIntervalBounds, and is PollerInterval until
+// the block time is observed
+func WithAdaptivePolling(enabled bool) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.adaptivePolling = enabled
+	}
+}
+
+// WithPollIntervalBounds sets the bounds of the poll interval with adaptive polling
+func WithPollIntervalBounds(minInterval time.Duration, maxInterval time.Duration) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.minPollInterval = minInterval
+		cp.maxPollInterval = maxInterval
+	}
+}
+
+// WithReliabilityWeighting scales the weight of each backend in weighted median mode by its reliability
+// score, so historically flaky backends count less than their static weight
+func WithReliabilityWeighting() ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.reliabilityWeighting = true
+	}
+}
+
+// WithBlockIDNormalizer sets how the block hashes fetched from the backends are validated and compared,
+// for chains with non-standard block identifiers. It defaults to NormalizeEVMBlockHash
+func WithBlockIDNormalizer(normalizer BlockIDNormalizer) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.blockIDNormalizer = normalizer
+	}
+}
+
+// WithRewindStrategy selects how the lowest block mode walks back to find the block the backends agree on
+func WithRewindStrategy(strategy RewindStrategy) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.rewindStrategy = strategy
+	}
+}
+
+// WithMaxBlockRange caps how many blocks below the lowest block the rewind goes,
+// giving up on the cycle when the backends don't agree within the range
+func WithMaxBlockRange(maxBlockRange uint64) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.maxBlockRange = maxBlockRange
+	}
+}
+
+// WithFailMode sets whether requests are served or rejected when there is no consensus group
+func WithFailMode(failMode FailMode) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.failMode = failMode
+	}
+}
+
+// WithMonotonicConsensus keeps the consensus block number from ever decreasing, for consumers treating it
+// as final. A lower proposal, i.e. on a reorg, is discarded and the current consensus is kept until the
+// backends catch back up past it
+func WithMonotonicConsensus(monotonic bool) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.monotonic = monotonic
+	}
+}
+
+// WithRequireManualRollback pauses the consensus instead of lowering it when every available backend reports a
+// block below the consensus block at once, i.e. on a coordinated rollback of the chain. The rollback is accepted
+// by the first cycle after an operator confirms it with ResumeConsensus
+func WithRequireManualRollback(require bool) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.manualRollback = require
+	}
+}
+
+// WithLogsRangeClamp clamps the block range of the eth_getLogs requests routed to the group to the consensus block
+// number, so clients don't get logs of blocks that are not agreed on yet and may be reorged. A fromBlock or toBlock
+// of latest or pending, or missing, is clamped, as is a toBlock past the consensus block; the ranges within the
+// consensus block are left as is, and the ranges starting past it are answered without logs, not forwarded
+func WithLogsRangeClamp() ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.clampLogsRange = true
+	}
+}
+
+// WithBlockNumberCap caps the eth_blockNumber responses routed to the group to the consensus block number,
+// and rewrites the latest tag of the eth_getBlockByNumber requests to it
+func WithBlockNumberCap() ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.capBlockNumber = true
+	}
+}
+
+// WithQuorum sets the number of backends that must agree in quorum mode
+func WithQuorum(quorum int) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.quorum = quorum
+	}
+}
+
+// WithWarmupCycles excludes a backend that comes back online from the consensus
+// until it has been successfully polled for the given number of cycles
+func WithWarmupCycles(cycles int) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.warmupCycles = cycles
+	}
+}
+
+// WithBreakerProbation excludes a backend that broke the consensus from the consensus
+// until it has been successfully polled for the given number of cycles. The consensus of the cycle
+// it broke is computed again without its vote
+func WithBreakerProbation(cycles int) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.probationCycles = cycles
+	}
+}
+
+// WithSyncStatusHeads polls the backends latest state with optimism_syncStatus instead of
+// eth_getBlockByNumber, so the consensus follows the unsafe L2 head reported by the rollup node.
+// The sync status is read from the rollup node set with WithSyncStatusURL, or from the rpc URL of a backend
+// without one, which must then serve both namespaces, i.e. a gateway in front of the rollup node and the
+// execution client. The blocks validating the consensus are always fetched from the rpc URL
+func WithSyncStatusHeads() ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.syncStatusHeads = true
+	}
+}
+
+// WithFinalizedOnly computes the consensus on the finalized heads of the backends instead of their latest
+// blocks, so the consensus can't reorg by construction. It also caps the routing to the consensus block,
+// see WithBlockNumberCap, so the requests for the latest block are served the finalized one
+func WithFinalizedOnly(enabled bool) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.finalizedOnly = enabled
+		if enabled {
+			cp.capBlockNumber = true
+		}
+	}
+}
+
+// WithTransactionsVerification also compares the transaction hashes of the proposed block across backends,
+// detecting divergences that don't surface in the block hash reported by the backends
+func WithTransactionsVerification() ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.verifyTransactions = true
+	}
+}
+
+// WithStrictChaining verifies that the proposed block of each backend in the consensus group links to the
+// block the group agrees on at the height below, excluding the backends with a broken parent link. The parent
+// hashes are read from the blocks fetched to check the agreement, at each step of the rewind, and the block
+// below is fetched from the group to check them against
+func WithStrictChaining() ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.strictChaining = true
+	}
+}
+
+// WithForkDetection excludes the minority of the backends when they split in two clusters
+// with different block hashes for the given number of consecutive cycles
+func WithForkDetection(cycles int) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.forkDetectionCycles = cycles
+	}
+}
+
+// WithStartupGracePeriod gives the backends time to settle after startup: during the grace period
+// divergence is logged, but doesn't ban backends nor break the consensus
+func WithStartupGracePeriod(gracePeriod time.Duration) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.startupGracePeriod = gracePeriod
+	}
+}
+
+// WithLogger sets the logger of the poller, the go-ethereum root logger by default
+func WithLogger(logger log.Logger) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.logger = logger
+	}
+}
+
+// WithConsensusHistorySize sets the number of past consensus blocks kept for GetRecentConsensus,
+// zero disables the history
+func WithConsensusHistorySize(size int) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.consensusHistorySize = size
+	}
+}
+
+// WithBackendBlockHistorySize sets the number of past polls kept per backend for GetBackendBlockHistory,
+// zero disables the history
+func WithBackendBlockHistorySize(size int) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.backendBlockHistorySize = size
+	}
+}
+
+// WithLazyConsensus polls the backends and computes the group consensus only when it is queried, through
+// GetConsensusGroup, GetConsensusBlockNumber or the routing, instead of on a timer, for low-traffic proxies.
+// A computed consensus is served for the given TTL before the next query recomputes it. The poller doesn't
+// start the timed polling then, and an async handler polling besides the queries is rejected by ValidateConfig
+func WithLazyConsensus(ttl time.Duration) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.lazyTTL = ttl
+	}
+}
+
+// WithRefreshDebounce sets the window where the calls to TriggerRefresh are coalesced in a single refresh
+func WithRefreshDebounce(debounce time.Duration) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.refreshDebounce = debounce
+	}
+}
+
+// WithHeadConsistencyCheck excludes a backend from the consensus while its latest block is more than
+// maxRegression blocks behind the latest block it previously reported
+func WithHeadConsistencyCheck(maxRegression uint64) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.maxHeadRegression = maxRegression
+	}
+}
+
+// WithMaxHeadAdvance excludes a backend from the consensus while its latest block is more than maxAdvance
+// blocks ahead of both the latest block it previously reported and the highest block of the other backends,
+// i.e. when its upstream flips to another chain. A backend catching up to the group is not flagged
+func WithMaxHeadAdvance(maxAdvance uint64) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.maxHeadAdvance = maxAdvance
+	}
+}
+
+// WithClockSkewDetection excludes a backend from the consensus while its latest block is dated more than
+// tolerance ahead of the local clock
+func WithClockSkewDetection(tolerance time.Duration) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.clockSkewTolerance = tolerance
+	}
+}
+
+// WithConfirmationDepth computes the consensus, and thus the routing head, on the blocks k confirmations
+// behind the head of each backend instead of on their latest blocks
+func WithConfirmationDepth(k uint64) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.confirmationDepth = k
+	}
+}
+
+// WithSlowPolls sets the number of consecutive polls a backend must be over its max latency before it is
+// excluded from voting, see WithConsensusMaxLatency
+func WithSlowPolls(polls int) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.slowPolls = polls
+	}
+}
+
+// WithConfirmationCycles keeps a freshly reached consensus block speculative until it is held for the given
+// number of cycles, after which it is confirmed, see IsConsensusBlockConfirmed
+func WithConfirmationCycles(cycles uint64) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.confirmationCycles = cycles
+	}
+}
+
+// WithLoadBalancerDetection fetches the latest block of a backend twice every interval polls, and excludes
+// it from the consensus while the two fetches return different hashes at the same height, i.e. its URL
+// fronts several nodes behind a load balancer
+func WithLoadBalancerDetection(interval int) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.loadBalancerCheckInterval = interval
+	}
+}
+
+// WithFrozenBackendDetection bans a backend whose latest block doesn't change for longer than
+// multiplier times the chain block time, while its peers advance past it
+func WithFrozenBackendDetection(blockTime time.Duration, multiplier int) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.frozenThreshold = blockTime * time.Duration(multiplier)
+	}
+}
+
+// WithStaleThreshold reports the consensus as stale, through CheckReady, GetFreshConsensusBlockNumber and
+// IsConsensusStale, when no cycle committed it for longer than threshold, i.e. while the poller is stalled
+func WithStaleThreshold(threshold time.Duration) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.staleThreshold = threshold
+	}
+}
+
+// WithOutlierDetection excludes a backend from the consensus while its latest block number is more than
+// sensitivity median absolute deviations away from the median of the group. The deviation is floored at one
+// block, so with the group in lockstep the backends more than sensitivity blocks away are excluded
+func WithOutlierDetection(sensitivity float64) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.outlierSensitivity = sensitivity
+	}
+}
+
+// WithPollBudget caps the polling requests sent to each backend to budget per window, i.e. to keep the
+// polling of a paid provider within a fraction of its request budget. Once a backend is over its budget,
+// its polls are skipped until the next window and its last state is kept
+func WithPollBudget(budget int, window time.Duration) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.pollBudget = budget
+		cp.pollBudgetWindow = window
+	}
+}
+
+// WithRateLimitedStateMaxAge lets a rate-limited backend take part in the consensus with its
+// cached state, as long as it was updated within maxAge, instead of being skipped
+func WithRateLimitedStateMaxAge(maxAge time.Duration) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.rateLimitedStateMaxAge = maxAge
+	}
+}
+
+// WithFetchErrorClassifier sets the classifier deciding whether a backend is banned,
+// backed off, or polled again after an error. By default errors are ignored
+func WithFetchErrorClassifier(classifier FetchErrorClassifier) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.errorClassifier = classifier
+	}
+}
+
+// WithCircuitBreaker pauses the polling of a backend after failureThreshold consecutive failed polls.
+// The backend is left out of the consensus while its circuit is open. After openPeriod, a single probe poll
+// decides whether polling resumes or stays paused for another period
+func WithCircuitBreaker(failureThreshold int, openPeriod time.Duration) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.circuitFailureThreshold = failureThreshold
+		cp.circuitOpenPeriod = openPeriod
+	}
+}
+
+// WithBanPeriod sets how long a banned backend is left out of the consensus
+func WithBanPeriod(banPeriod time.Duration) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.banPeriod = banPeriod
+	}
+}
+
+// WithErrorBackoff sets how long a backend is left unpolled after an error classified as FetchErrorBackoff.
+// The period doubles with each consecutive backoff, up to 32 times, until the backend is polled successfully
+func WithErrorBackoff(backoff time.Duration) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.errorBackoff = backoff
+	}
+}
+
+// WithWorkerPoolSize sets the maximum number of concurrent block fetches while validating the consensus
+func WithWorkerPoolSize(size int) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.workerPoolSize = size
+	}
+}
+
+// WithPollSampleSize bounds the number of backends polled per cycle to n, rotating through the group.
+// The consensus is still computed over the last polled state of all the backends
+func WithPollSampleSize(n int) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.pollSampleSize = n
+	}
+}
+
+// WithMaxConcurrentFetches limits the total in-flight requests to the backends across the poller,
+// including the per-backend polls and the consensus validation. n must be positive
+func WithMaxConcurrentFetches(n int) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.maxConcurrentFetches = n
+		cp.fetches = semaphore.NewWeighted(int64(n))
+	}
+}
+
+// WithGroupDebounce only reflects a backend joining or leaving the consensus group in the routing once it
+// was consistently in or out for the given number of cycles, so a flapping backend doesn't churn the routing.
+// The consensus itself is still computed from the consensus group of every cycle
+func WithGroupDebounce(cycles int) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.groupDebounceCycles = cycles
+	}
+}
+
+// WithGroupStateLogInterval sets how many cycles an unchanged group state is left out of the logs
+func WithGroupStateLogInterval(cycles int) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.groupStateLogInterval = cycles
+	}
+}
+
+func NewConsensusPoller(bg *BackendGroup, opts ...ConsensusOpt) *ConsensusPoller {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+
+	state := make(map[*Backend]*backendState, len(bg.Backends))
+	for _, be := range bg.Backends {
+		state[be] = &backendState{}
+	}
+
+	cp := &ConsensusPoller{
+		ctx:          ctx,
+		cancelFunc:   cancelFunc,
+		backendGroup: bg,
+		backendState: state,
+		startedAt:    time.Now(),
+		health:       NoConsensus,
+
+		mode:                    ConsensusModeLowestBlock,
+		rewindStrategy:          RewindLinear,
+		failMode:                FailOpen,
+		groupStateLogInterval:   DefaultGroupStateLogInterval,
+		banPeriod:               DefaultBanPeriod,
+		errorBackoff:            DefaultErrorBackoff,
+		workerPoolSize:          DefaultWorkerPoolSize,
+		refreshC:                make(chan struct{}, 1),
+		refreshDebounce:         DefaultRefreshDebounce,
+		slowPolls:               DefaultSlowPolls,
+		webhookMinInterval:      DefaultWebhookMinInterval,
+		consensusHistorySize:    DefaultConsensusHistorySize,
+		backendBlockHistorySize: DefaultBackendBlockHistorySize,
+		minPollInterval:         DefaultMinPollInterval,
+		maxPollInterval:         DefaultMaxPollInterval,
+		blockIDNormalizer:       NormalizeEVMBlockHash,
+		logger:                  log.Root(),
+	}
+
+	for _, opt := range opts {
+		opt(cp)
+	}
+	if cp.mode == ConsensusModeFinalizedQuorum {
+		WithFinalizedOnly(true)(cp)
+	}
+
+	cp.workers = semaphore.NewWeighted(int64(cp.workerPoolSize))
+
+	if cp.referenceURL != "" {
+		cp.reference = NewBackend("reference", cp.referenceURL, "", noopBackendRateLimiter, semaphore.NewWeighted(1), WithStrippedTrailingXFF())
+		state[cp.reference] = &backendState{}
+	}
+	for _, be := range cp.shadowBackends {
+		state[be] = &backendState{}
+	}
+	if cp.pollHTTP2 {
+		if cp.client != nil {
+			cp.client = forceHTTP2(cp.client)
+		} else {
+			for be, bs := range state {
+				bs.pollClient = forceHTTP2(&be.client.Client)
+			}
+		}
+	}
+	// the recording and the replay are per backend, as the polls are recorded under the backend name
+	if cp.pollRecorder != nil {
+		for be, bs := range state {
+			client := cp.client
+			if client == nil {
+				client = bs.pollClient
+			}
+			if client == nil {
+				client = &be.client.Client
+			}
+			bs.pollClient = cp.pollRecorder.wrap(client, be.Name)
+		}
+	}
+	if cp.pollReplay != nil {
+		for be, bs := range state {
+			bs.pollClient = cp.pollReplay.client(be.Name)
+		}
+	}
+
+	if cp.tracker == nil {
+		cp.tracker = NewInMemoryConsensusTracker()
+	}
+
+	if cp.webhookURL != "" {
+		cp.webhookClient = &http.Client{Timeout: 5 * time.Second}
+		cp.webhookEvents = make(chan ConsensusEvent, webhookBufferSize)
+		cp.webhookLastSent = make(map[ConsensusEventType]time.Time)
+		go cp.dispatchWebhook()
+	}
+
+	if cp.asyncHandler == nil && cp.lazyTTL > 0 {
+		cp.asyncHandler = NewNoopAsyncHandler()
+	}
+	if cp.asyncHandler == nil {
+		cp.asyncHandler = NewPollerAsyncHandler(ctx, cp)
+	}
+
+	cp.asyncHandler.Init()
+
+	return cp
+}
+
+// ValidateConfig checks the group and the options of the poller, and returns a descriptive error for the
+// first misconfiguration found, i.e. an empty group, duplicate backend names or a negative interval
+func (cp *ConsensusPoller) ValidateConfig() error {
+	group := cp.backendGroup.Name
+	if len(cp.backendGroup.Backends) == 0 {
+		return fmt.Errorf("backend group %s has no backends", group)
+	}
+	names := make(map[string]bool, len(cp.backendGroup.Backends))
+	for _, be := range cp.backendGroup.Backends {
+		if names[be.Name] {
+			return fmt.Errorf("duplicate backend %s in backend group %s", be.Name, group)
+		}
+		names[be.Name] = true
+	}
+	for _, be := range cp.shadowBackends {
+		if names[be.Name] {
+			return fmt.Errorf("shadow backend %s is already in backend group %s", be.Name, group)
+		}
+	}
+
+	switch cp.mode {
+	case ConsensusModeLowestBlock, ConsensusModeQuorum, ConsensusModeWeightedMedian, ConsensusModeFinalizedQuorum:
+	case ConsensusModeHeadOnly:
+		// the consensus has no block hash to check the features comparing hashes against
+		if cp.strictChaining || cp.referenceURL != "" || len(cp.shadowBackends) > 0 {
+			return fmt.Errorf("consensus mode %s doesn't support strict chaining, a reference endpoint nor shadow backends for backend group %s", cp.mode, group)
+		}
+	case ConsensusModeSingleBackend:
+		if len(cp.backendGroup.Backends) != 1 {
+			return fmt.Errorf("consensus mode %s requires a single backend, backend group %s has %d", cp.mode, group, len(cp.backendGroup.Backends))
+		}
+	default:
+		return fmt.Errorf("unknown consensus mode %s for backend group %s", cp.mode, group)
+	}
+	switch cp.rewindStrategy {
+	case RewindLinear, RewindBatched:
+	default:
+		return fmt.Errorf("unknown consensus rewind strategy %s for backend group %s", cp.rewindStrategy, group)
+	}
+	switch cp.failMode {
+	case FailOpen, FailClosed:
+	default:
+		return fmt.Errorf("unknown consensus fail mode %s for backend group %s", cp.failMode, group)
+	}
+
+	if cp.quorum < 0 || cp.quorum > len(cp.backendGroup.Backends) {
+		return fmt.Errorf("consensus quorum %d is out of the [0, %d] range of backend group %s", cp.quorum, len(cp.backendGroup.Backends), group)
+	}
+	if cp.workerPoolSize <= 0 {
+		return fmt.Errorf("consensus worker pool size %d is not positive for backend group %s", cp.workerPoolSize, group)
+	}
+	if cp.fetches != nil && cp.maxConcurrentFetches <= 0 {
+		return fmt.Errorf("consensus max concurrent fetches %d is not positive for backend group %s", cp.maxConcurrentFetches, group)
+	}
+	if cp.groupStateLogInterval <= 0 {
+		return fmt.Errorf("group state log interval %d is not positive for backend group %s", cp.groupStateLogInterval, group)
+	}
+
+	counts := []struct {
+		name  string
+		value int
+	}{
+		{"poll sample size", cp.pollSampleSize},
+		{"consensus history size", cp.consensusHistorySize},
+		{"backend block history size", cp.backendBlockHistorySize},
+		{"warmup cycles", cp.warmupCycles},
+		{"breaker probation cycles", cp.probationCycles},
+		{"fork detection cycles", cp.forkDetectionCycles},
+		{"slow polls", cp.slowPolls},
+		{"group debounce cycles", cp.groupDebounceCycles},
+		{"load balancer check interval", cp.loadBalancerCheckInterval},
+		{"circuit breaker failures", cp.circuitFailureThreshold},
+		{"poll budget", cp.pollBudget},
+	}
+	for _, c := range counts {
+		if c.value < 0 {
+			return fmt.Errorf("consensus %s %d is negative for backend group %s", c.name, c.value, group)
+		}
+	}
+
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"ban period", cp.banPeriod},
+		{"error backoff", cp.errorBackoff},
+		{"startup grace period", cp.startupGracePeriod},
+		{"frozen block threshold", cp.frozenThreshold},
+		{"rate limited state max age", cp.rateLimitedStateMaxAge},
+		{"circuit breaker open period", cp.circuitOpenPeriod},
+		{"refresh debounce", cp.refreshDebounce},
+		{"min poll interval", cp.minPollInterval},
+		{"max poll interval", cp.maxPollInterval},
+		{"clock skew tolerance", cp.clockSkewTolerance},
+		{"stale threshold", cp.staleThreshold},
+		{"reliability half-life", cp.reliabilityHalfLife},
+		{"poll budget window", cp.pollBudgetWindow},
+		{"lazy TTL", cp.lazyTTL},
+	}
+	for _, d := range durations {
+		if d.value < 0 {
+			return fmt.Errorf("consensus %s %s is negative for backend group %s", d.name, d.value, group)
+		}
+	}
+
+	if cp.outlierSensitivity < 0 {
+		return fmt.Errorf("consensus outlier sensitivity %g is negative for backend group %s", cp.outlierSensitivity, group)
+	}
+	if cp.pollBudget > 0 && cp.pollBudgetWindow == 0 {
+		return fmt.Errorf("consensus poll budget window is required with a poll budget for backend group %s", group)
+	}
+	if cp.circuitFailureThreshold > 0 && cp.circuitOpenPeriod == 0 {
+		return fmt.Errorf("consensus circuit breaker open period is required with circuit breaker failures for backend group %s", group)
+	}
+	if cp.adaptivePolling && cp.minPollInterval > cp.maxPollInterval {
+		return fmt.Errorf("consensus min poll interval %s is above the max poll interval %s for backend group %s", cp.minPollInterval, cp.maxPollInterval, group)
+	}
+	if _, noop := cp.asyncHandler.(*NoopAsyncHandler); cp.lazyTTL > 0 && !noop {
+		return fmt.Errorf("consensus lazy TTL doesn't support an async handler polling the backends for backend group %s", group)
+	}
+	return nil
+}
+
+// UpdateBackend refreshes the consensus state of a single backend
+func (cp *ConsensusPoller) UpdateBackend(ctx context.Context, be *Backend) {
+	if !be.votesInConsensus() {
+		return
+	}
+
+	// the blocks fetched from a URL swapped out in the meantime are not recorded
+	_, generation := be.getRPCURL()
+
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	bannedUntil, backoffUntil := bs.bannedUntil, bs.backoffUntil
+	bs.backendStateMux.Unlock()
+	if time.Now().Before(bannedUntil) {
+		cp.logger.Warn("skipping backend banned", "backend", be.Name, "bannedUntil", bannedUntil)
+		return
+	}
+
+	if time.Now().Before(backoffUntil) {
+		return
+	}
+
+	if be.IsRateLimited() {
+		return
+	}
+
+	if !be.Online() {
+		cp.setBackendUnavailable(be)
+		return
+	}
+
+	if cp.pollBudget > 0 && !cp.withinPollBudget(be) {
+		cp.logger.Debug("skipping backend over its poll budget", "name", be.Name, "budget", cp.pollBudget, "window", cp.pollBudgetWindow)
+		RecordConsensusBackendPollThrottled(cp.backendGroup, be)
+		return
+	}
+
+	// checked last, as a half-open circuit lets a single probe through, which must then be polled
+	if cp.circuitFailureThreshold > 0 && !cp.allowPoll(be) {
+		return
+	}
+
+	// we'll introduce here checks to ban the backend
+	// i.e. node is syncing the chain
+
+	// then update backend consensus
+
+	var headLatency time.Duration
+	latestBlockNumber, latestBlockHash, latestBlockTimestamp, err := cp.fetchHead(context.WithValue(ctx, headLatencyKey{}, &headLatency), be)
+	if _, current := be.getRPCURL(); current != generation {
+		cp.logger.Debug("discarding poll of a swapped backend URL", "name", be.Name)
+		if cp.circuitFailureThreshold > 0 {
+			cp.releaseProbe(be)
+		}
+		return
+	}
+	if err != nil {
+		cp.logger.Warn("error updating backend", "name", be.Name, "err", err)
+		cp.setBackendUnavailable(be)
+		cp.recordReliability(be, true)
+		if cp.circuitFailureThreshold > 0 {
+			cp.recordPollFailure(be)
+		}
+		cp.handleFetchError(be, err)
+		return
+	}
+	if cp.circuitFailureThreshold > 0 {
+		cp.recordPollSuccess(be)
+	}
+	if be.consensusMaxLatency > 0 {
+		cp.checkLatency(be, headLatency)
+	}
+
+	if cp.clockSkewTolerance > 0 {
+		if err := cp.checkClockSkew(be, latestBlockTimestamp, time.Now()); err != nil {
+			cp.logger.Warn("backend reported a latest block dated in the future", "name", be.Name, "err", err)
+			RecordConsensusClockSkew(cp.backendGroup, be)
+			cp.handleFetchError(be, err)
+			return
+		}
+	}
+
+	if cp.loadBalancerCheckInterval > 0 {
+		if err := cp.checkEndpointConsistency(ctx, be, latestBlockNumber, latestBlockHash); err != nil {
+			cp.logger.Warn("backend endpoint returned inconsistent latest blocks", "name", be.Name, "err", err)
+			cp.handleFetchError(be, err)
+			return
+		}
+	}
+
+	// with a confirmation depth, the state of the backend is its block that many confirmations behind its head
+	headBlockNumber := latestBlockNumber
+	if cp.confirmationDepth > 0 {
+		if uint64(headBlockNumber) <= cp.confirmationDepth {
+			cp.logger.Debug("backend head is within the confirmation depth", "name", be.Name, "head", headBlockNumber, "confirmationDepth", cp.confirmationDepth)
+			return
+		}
+		// the head is above the confirmation depth, the confirmed block is at least block 1
+		confirmedBlock := headBlockNumber - hexutil.Uint64(cp.confirmationDepth)
+		latestBlockNumber, latestBlockHash, _, err = cp.fetchBlock(ctx, be, confirmedBlock.String())
+		if err != nil {
+			cp.logger.Warn("error fetching confirmed block", "name", be.Name, "block", confirmedBlock, "err", err)
+			cp.setBackendUnavailable(be)
+			cp.recordReliability(be, true)
+			cp.handleFetchError(be, err)
+			return
+		}
+	}
+
+	if cp.maxHeadRegression > 0 || cp.maxHeadAdvance > 0 {
+		if err := cp.checkHeadConsistency(be, latestBlockNumber); err != nil {
+			cp.logger.Warn("backend reported an inconsistent latest block", "name", be.Name, "err", err)
+			RecordConsensusInconsistentHead(cp.backendGroup, be)
+			cp.handleFetchError(be, err)
+			return
+		}
+	}
+
+	cp.recordReliability(be, false)
+	bs.backendStateMux.Lock()
+	bs.backoffCount = 0
+	bs.backendStateMux.Unlock()
+	changed, current := cp.setBackendStateOfURL(be, generation, latestBlockNumber, latestBlockHash)
+	if !current {
+		cp.logger.Debug("discarding poll of a swapped backend URL", "name", be.Name)
+		if cp.circuitFailureThreshold > 0 {
+			cp.releaseProbe(be)
+		}
+		return
+	}
+
+	if changed {
+		RecordBackendLatestBlock(cp.backendGroup, be, headBlockNumber)
+		cp.logger.Info("backend state updated", "name", be.Name, "state", bs)
+	}
+}
+
+// UpdateBackends concurrently refreshes the consensus state of the backends: all of them, or the
+// next rotating sample of them when a poll sample size is set
+func (cp *ConsensusPoller) UpdateBackends(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, be := range cp.nextPollSample() {
+		wg.Add(1)
+		go func(be *Backend) {
+			defer wg.Done()
+			cp.UpdateBackend(ctx, be)
+		}(be)
+	}
+	wg.Wait()
+}
+
+// nextPollSample returns the backends to poll in this cycle, and moves the rotating window forward
+func (cp *ConsensusPoller) nextPollSample() []*Backend {
+	backends := cp.backendGroup.Backends
+	if cp.pollSampleSize <= 0 || cp.pollSampleSize >= len(backends) {
+		return backends
+	}
+
+	cp.pollSampleMux.Lock()
+	defer cp.pollSampleMux.Unlock()
+	sample := make([]*Backend, 0, cp.pollSampleSize)
+	for i := 0; i < cp.pollSampleSize; i++ {
+		sample = append(sample, backends[(cp.pollSampleOffset+i)%len(backends)])
+	}
+	cp.pollSampleOffset = (cp.pollSampleOffset + cp.pollSampleSize) % len(backends)
+	return sample
+}
+
+// consensusProposal is the outcome of a consensus resolution cycle
+type consensusProposal struct {
+	blockNumber hexutil.Uint64
+	blockHash   string
+	// timestamp is the timestamp of the proposed block from the fetch validating its hash, zero when not fetched
+	timestamp        uint64
+	backends         []*Backend
+	filteredBackends []string
+	broken           bool
+	// breaker is the name of the backend that broke the consensus first
+	breaker string
+}
+
+// UpdateBackendGroupConsensus resolves the current group consensus based on the state of the backends.
+// The concurrent calls are serialized
+func (cp *ConsensusPoller) UpdateBackendGroupConsensus(ctx context.Context) {
+	cp.cycleMux.Lock()
+	defer cp.cycleMux.Unlock()
+
+	start := time.Now()
+	currentConsensusBlockNumber := cp.tracker.GetConsensusBlockNumber()
+	// the observers are notified of every cycle, the ones committing no proposal included
+	var result CycleResult
+	defer func() {
+		if !result.Committed {
+			result = cp.uncommittedCycleResult()
+		}
+		result.Duration = time.Since(start)
+		cp.notifyObservers(result)
+	}()
+	// the health reflects the state the cycle leaves, whether it commits a proposal or not
+	defer cp.updateConsensusHealth()
+
+	cp.recordBackendStateAges()
+	cp.countStableCycles()
+	if cp.frozenThreshold > 0 && !cp.inGracePeriod() && !cp.IsConsensusPaused() {
+		cp.banFrozenBackends()
+	}
+	if cp.outlierSensitivity > 0 {
+		cp.flagOutlierBackends()
+	}
+
+	proposal := cp.propose(ctx, currentConsensusBlockNumber)
+
+	// no block to propose (i.e. initializing consensus)
+	if proposal == nil {
+		if cp.webhookURL != "" && cp.allBackendsDown() {
+			cp.emitEvent(ConsensusEvent{
+				Event:               ConsensusEventAllDown,
+				BlockNumber:         currentConsensusBlockNumber,
+				PreviousBlockNumber: currentConsensusBlockNumber,
+			})
+		}
+		return
+	}
+	if proposal.blockHash == "" && cp.mode != ConsensusModeHeadOnly {
+		cp.logger.Warn("skipping consensus proposal without a validated block hash", "proposedBlock", proposal.blockNumber)
+		return
+	}
+	// a delta persistently at zero or below means the consensus is stalled or regressing
+	RecordGroupConsensusProposalDelta(cp.backendGroup, int64(proposal.blockNumber)-int64(currentConsensusBlockNumber))
+	if cp.IsConsensusPaused() {
+		cp.logger.Debug("consensus paused, ignoring proposal", "currentConsensusBlockNumber", currentConsensusBlockNumber, "proposedBlock", proposal.blockNumber, "proposedBlockHash", proposal.blockHash, "broken", proposal.broken)
+		return
+	}
+
+	if highestBlock, _ := cp.GetHighestBlock(); proposal.blockNumber < highestBlock {
+		cp.logger.Info("no agreement at head", "proposedBlock", proposal.blockNumber, "highestBlock", highestBlock)
+		RecordGroupConsensusNoAgreementAtHead(cp.backendGroup)
+	}
+
+	if proposal.broken && cp.inGracePeriod() {
+		cp.logger.Info("ignoring consensus broken during the startup grace period", "currentConsensusBlockNumber", currentConsensusBlockNumber, "proposedBlock", proposal.blockNumber, "proposedBlockHash", proposal.blockHash)
+		proposal.broken = false
+	}
+
+	// a reorg suppressed in monotonic mode or a rollback held for confirmation is not committed, so the consensus
+	// is not broken: the breakers are neither looked for nor put on probation
+	rollbackAccepted := false
+	withheld := func() bool {
+		if cp.monotonic && proposal.blockNumber < currentConsensusBlockNumber {
+			cp.logger.Error("suppressing consensus reorg below the committed block", "group", cp.backendGroup.Name, "currentConsensusBlockNumber", currentConsensusBlockNumber, "proposedBlock", proposal.blockNumber, "proposedBlockHash", proposal.blockHash)
+			return true
+		}
+		if cp.manualRollback && !rollbackAccepted && proposal.blockNumber < currentConsensusBlockNumber && cp.isCoordinatedRollback(currentConsensusBlockNumber) {
+			if !cp.takeRollbackConfirmation() {
+				cp.holdRollback(currentConsensusBlockNumber, proposal)
+				return true
+			}
+			rollbackAccepted = true
+			cp.logger.Warn("accepting the confirmed coordinated rollback", "group", cp.backendGroup.Name, "currentConsensusBlockNumber", currentConsensusBlockNumber, "proposedBlock", proposal.blockNumber, "proposedBlockHash", proposal.blockHash)
+		}
+		return false
+	}
+	if withheld() {
+		return
+	}
+
+	if proposal.broken {
+		// propagate event to other interested parts, such as cache invalidator
+		cp.logger.Info("consensus broken", "currentConsensusBlockNumber", currentConsensusBlockNumber, "proposedBlock", proposal.blockNumber, "proposedBlockHash", proposal.blockHash)
+
+		// the backends rolled back together on a confirmed rollback, none of them broke the consensus
+		if !rollbackAccepted && cp.handleBreakers(ctx, currentConsensusBlockNumber) && cp.probationCycles > 0 {
+			// the breakers voted in the proposal, which is computed again without them
+			proposal = cp.propose(ctx, currentConsensusBlockNumber)
+			if proposal == nil {
+				return
+			}
+			if proposal.blockHash == "" && cp.mode != ConsensusModeHeadOnly {
+				cp.logger.Warn("skipping consensus proposal without a validated block hash", "proposedBlock", proposal.blockNumber)
+				return
+			}
+			if withheld() {
+				return
+			}
+		}
+	}
+
+	cp.consensusGroupMux.Lock()
+	changed := proposal.blockNumber != currentConsensusBlockNumber || proposal.blockHash != cp.consensusHash
+	timestamp := cp.consensusTimestamp
+	previousGroupSize := len(cp.consensusGroup)
+	previousHash := cp.consensusHash
+	cp.consensusGroupMux.Unlock()
+	if changed && cp.mode != ConsensusModeHeadOnly {
+		timestamp = proposal.timestamp
+		if timestamp == 0 {
+			timestamp = cp.fetchConsensusBlockTimestamp(ctx, proposal)
+		}
+	}
+
+	confirmations := uint64(0)
+	if !changed {
+		confirmations = cp.tracker.GetConsensusConfirmations() + 1
+	}
+	cp.tracker.SetConsensusBlockNumber(proposal.blockNumber)
+	cp.tracker.SetConsensusConfirmations(confirmations)
+	RecordGroupConsensusLatestBlock(cp.backendGroup, proposal.blockNumber)
+	if cp.adaptivePolling {
+		cp.observeBlockTime(proposal.blockNumber, time.Now())
+	}
+	cp.consensusGroupMux.Lock()
+	cp.updateRoutingGroupLocked(proposal.backends, cp.routingGroupStreaks == nil)
+	cp.consensusGroup = proposal.backends
+	if changed {
+		cp.recordConsensusHistory(ConsensusEntry{
+			BlockNumber: proposal.blockNumber,
+			BlockHash:   proposal.blockHash,
+			Time:        time.Now(),
+		})
+	}
+	if proposal.broken {
+		cp.lastReorg = &ReorgInfo{
+			Time:           time.Now(),
+			OldBlockNumber: currentConsensusBlockNumber,
+			OldBlockHash:   cp.consensusHash,
+			NewBlockNumber: proposal.blockNumber,
+			NewBlockHash:   proposal.blockHash,
+			Backend:        proposal.breaker,
+		}
+	}
+	cp.consensusHash = proposal.blockHash
+	cp.consensusTimestamp = timestamp
+	cp.committedAt = time.Now()
+	if confirmations >= cp.confirmationCycles {
+		cp.confirmedBlockNumber = proposal.blockNumber
+	}
+	cp.consensusGroupMux.Unlock()
+
+	consensusBackendsNames := make([]string, 0, len(proposal.backends))
+	inGroup := make(map[*Backend]bool, len(proposal.backends))
+	for _, be := range proposal.backends {
+		consensusBackendsNames = append(consensusBackendsNames, be.Name)
+		inGroup[be] = true
+	}
+	for _, be := range cp.backendGroup.Backends {
+		RecordConsensusBackendInGroup(cp.backendGroup, be, inGroup[be])
+	}
+	if cp.webhookURL != "" {
+		event := ConsensusEvent{
+			BlockNumber:         proposal.blockNumber,
+			BlockHash:           proposal.blockHash,
+			PreviousBlockNumber: currentConsensusBlockNumber,
+			ConsensusGroup:      consensusBackendsNames,
+		}
+		// the consensus moving to another hash at or below the committed block is a reorg, even when all
+		// the backends agree on it
+		reorged := changed && previousHash != "" && proposal.blockNumber <= currentConsensusBlockNumber
+		if proposal.broken || reorged {
+			event.Event = ConsensusEventReorg
+			cp.emitEvent(event)
+		}
+		if len(proposal.backends) < previousGroupSize {
+			event.Event = ConsensusEventGroupShrink
+			cp.emitEvent(event)
+		}
+	}
+	consensusGroupNames := strings.Join(consensusBackendsNames, ", ")
+	filteredGroupNames := strings.Join(proposal.filteredBackends, ", ")
+	if cp.sampleGroupStateLog(proposal.blockNumber, consensusGroupNames, filteredGroupNames, proposal.broken) {
+		cp.logger.Info("group state", "proposedBlock", proposal.blockNumber, "consensusBackends", consensusGroupNames, "filteredBackends", filteredGroupNames)
+	}
+
+	if proposal.blockNumber != currentConsensusBlockNumber {
+		cp.notifyBlockSubscribers(proposal.blockNumber)
+	}
+	result = CycleResult{
+		BlockNumber:      proposal.blockNumber,
+		BlockHash:        proposal.blockHash,
+		ConsensusGroup:   consensusBackendsNames,
+		FilteredBackends: append([]string(nil), proposal.filteredBackends...),
+		Broken:           proposal.broken,
+		Committed:        true,
+	}
+
+	if cp.reference != nil {
+		cp.checkReference(ctx, proposal.blockNumber, proposal.blockHash)
+	}
+	if len(cp.shadowBackends) > 0 {
+		cp.checkShadowBackends(ctx, proposal.blockNumber, proposal.blockHash)
+	}
+}
+
+// propose computes the consensus proposal of the consensus mode, nil when there is no block to propose
+func (cp *ConsensusPoller) propose(ctx context.Context, currentConsensusBlockNumber hexutil.Uint64) *consensusProposal {
+	switch cp.mode {
+	case ConsensusModeQuorum, ConsensusModeFinalizedQuorum:
+		return cp.proposeQuorumConsensus(ctx, currentConsensusBlockNumber)
+	case ConsensusModeWeightedMedian:
+		return cp.proposeWeightedMedianConsensus(ctx, currentConsensusBlockNumber)
+	case ConsensusModeSingleBackend:
+		return cp.proposeSingleBackendConsensus()
+	case ConsensusModeHeadOnly:
+		return cp.proposeHeadOnlyConsensus()
+	default:
+		return cp.proposeLowestBlockConsensus(ctx, currentConsensusBlockNumber)
+	}
+}
+
+// checkShadowBackends polls the shadow backends and records how far each is from the consensus, and
+// whether it has another hash for the consensus block. Their state is never read by the consensus
+func (cp *ConsensusPoller) checkShadowBackends(ctx context.Context, blockNumber hexutil.Uint64, blockHash string) {
+	err := cp.runConcurrently(ctx, len(cp.shadowBackends), func(i int) {
+		be := cp.shadowBackends[i]
+		latestBlockNumber, _, err := cp.fetchLatestBlock(ctx, be)
+		if err != nil {
+			cp.logger.Warn("error polling shadow backend", "name", be.Name, "err", err)
+			return
+		}
+		RecordConsensusShadowBackendLag(cp.backendGroup, be, int64(blockNumber)-int64(latestBlockNumber))
+		if latestBlockNumber < blockNumber {
+			return
+		}
+
+		_, shadowBlockHash, _, err := cp.fetchBlock(ctx, be, blockNumber.String())
+		if err != nil {
+			cp.logger.Warn("error polling shadow backend", "name", be.Name, "err", err)
+			return
+		}
+		if shadowBlockHash != blockHash {
+			cp.logger.Warn("shadow backend diverges from the consensus", "group", cp.backendGroup.Name, "name", be.Name, "consensusBlock", blockNumber, "consensusHash", blockHash, "shadowHash", shadowBlockHash)
+			RecordConsensusShadowBackendDivergence(cp.backendGroup, be)
+		}
+	})
+	if err != nil {
+		cp.logger.Warn("error polling shadow backends", "err", err)
+	}
+}
+
+// observeBlockTime feeds the consensus block observed at the given time into the block time average,
+// only the advances of the consensus count, as the time between two of them spans the blocks in between
+func (cp *ConsensusPoller) observeBlockTime(blockNumber hexutil.Uint64, now time.Time) {
+	cp.blockTimeMux.Lock()
+	defer cp.blockTimeMux.Unlock()
+
+	if cp.lastAdvanceTime.IsZero() || blockNumber < cp.lastAdvanceBlockNumber {
+		cp.lastAdvanceBlockNumber = blockNumber
+		cp.lastAdvanceTime = now
+		return
+	}
+	if blockNumber == cp.lastAdvanceBlockNumber {
+		return
+	}
+
+	sample := now.Sub(cp.lastAdvanceTime) / time.Duration(blockNumber-cp.lastAdvanceBlockNumber)
+	if cp.observedBlockTime == 0 {
+		cp.observedBlockTime = sample
+	} else {
+		cp.observedBlockTime = time.Duration(blockTimeEWMAWeight*float64(sample) + (1-blockTimeEWMAWeight)*float64(cp.observedBlockTime))
+	}
+	cp.lastAdvanceBlockNumber = blockNumber
+	cp.lastAdvanceTime = now
+}
+
+// pollInterval returns the interval between two polls, derived from the observed block time with adaptive polling
+func (cp *ConsensusPoller) pollInterval() time.Duration {
+	if !cp.adaptivePolling {
+		return PollerInterval
+	}
+
+	cp.blockTimeMux.Lock()
+	blockTime := cp.observedBlockTime
+	cp.blockTimeMux.Unlock()
+	if blockTime == 0 {
+		return PollerInterval
+	}
+
+	interval := time.Duration(adaptivePollRatio * float64(blockTime))
+	if interval < cp.minPollInterval {
+		return cp.minPollInterval
+	}
+	if interval > cp.maxPollInterval {
+		return cp.maxPollInterval
+	}
+	return interval
+}
+
+// fetchConsensusBlockTimestamp fetches the proposed block from the proposed group, and returns the timestamp
+// of the first one served with the proposed hash, so it is the timestamp of the agreed block. It is only needed
+// when the proposed block was fetched from none of the voters, i.e. they vouched for it with their cached state,
+// and returns zero when no backend served it
+func (cp *ConsensusPoller) fetchConsensusBlockTimestamp(ctx context.Context, proposal *consensusProposal) uint64 {
+	for _, be := range proposal.backends {
+		jsonMap, err := cp.requestBlock(ctx, be, proposal.blockNumber.String(), false)
+		if err != nil {
+			cp.logger.Debug("error fetching consensus block timestamp", "name", be.Name, "err", err)
+			continue
+		}
+		_, blockHash, _, err := cp.parseBlock(be, jsonMap)
+		if err != nil || blockHash != proposal.blockHash {
+			continue
+		}
+		if timestamp, err := parseQuantity(jsonMap["timestamp"]); err == nil {
+			return uint64(timestamp)
+		}
+	}
+	cp.logger.Debug("consensus block timestamp not available", "proposedBlock", proposal.blockNumber, "proposedBlockHash", proposal.blockHash)
+	return 0
+}
+
+// checkReference polls the reference endpoint and alerts when the consensus is further from its latest
+// block than the max divergence, or when the reference has another hash for the consensus block
+func (cp *ConsensusPoller) checkReference(ctx context.Context, blockNumber hexutil.Uint64, blockHash string) {
+	referenceBlockNumber, _, err := cp.fetchLatestBlock(ctx, cp.reference)
+	if err != nil {
+		cp.logger.Warn("error polling reference endpoint", "err", err)
+		return
+	}
+
+	var divergence uint64
+	if blockNumber > referenceBlockNumber {
+		divergence = uint64(blockNumber - referenceBlockNumber)
+	} else {
+		divergence = uint64(referenceBlockNumber - blockNumber)
+	}
+	if divergence > cp.referenceMaxDivergence {
+		cp.logger.Warn("consensus diverges from the reference endpoint", "group", cp.backendGroup.Name, "consensusBlock", blockNumber, "referenceBlock", referenceBlockNumber, "maxDivergence", cp.referenceMaxDivergence)
+		RecordGroupConsensusReferenceDivergence(cp.backendGroup)
+		return
+	}
+	if referenceBlockNumber < blockNumber {
+		return
+	}
+
+	_, referenceBlockHash, _, err := cp.fetchBlock(ctx, cp.reference, blockNumber.String())
+	if err != nil {
+		cp.logger.Warn("error polling reference endpoint", "err", err)
+		return
+	}
+	if referenceBlockHash != blockHash {
+		cp.logger.Warn("consensus block hash differs from the reference endpoint", "group", cp.backendGroup.Name, "consensusBlock", blockNumber, "consensusHash", blockHash, "referenceHash", referenceBlockHash)
+		RecordGroupConsensusReferenceDivergence(cp.backendGroup)
+	}
+}
+
+// proposeLowestBlockConsensus anchors the consensus on the lowest block across the backends,
+// and walks back until all of them agree on the block hash
+func (cp *ConsensusPoller) proposeLowestBlockConsensus(ctx context.Context, currentConsensusBlockNumber hexutil.Uint64) *consensusProposal {
+	lowestBlock, lowestBlockHash, anchor := cp.lowestBlock(func(be *Backend) bool {
+		return !cp.isBanned(be) && !cp.isExcludedFromVoting(be)
+	})
+	if lowestBlock == 0 {
+		return nil
+	}
+
+	// the blocks fetched to detect the forks are reused to check the agreement, so the detection
+	// doesn't add a fetch round to the cycle
+	var prefetched map[*Backend]blockResult
+	if cp.forkDetectionCycles > 0 && !cp.inGracePeriod() {
+		prefetched = cp.detectForks(ctx, lowestBlock)
+	}
+
+	// the backend defining the lowest block may have been banned, excluded or gone offline since, i.e. while
+	// detecting forks; the lowest block is then re-resolved among the eligible backends, instead of rewinding
+	// from a block none of the voters vouches for
+	if filtered, _ := cp.isFiltered(anchor); filtered {
+		previousLowestBlock := lowestBlock
+		lowestBlock, lowestBlockHash, anchor = cp.lowestBlock(func(be *Backend) bool {
+			filtered, _ := cp.isFiltered(be)
+			return !filtered
+		})
+		if lowestBlock == 0 {
+			return nil
+		}
+		cp.logger.Debug("lowest block backend became ineligible, re-resolving the lowest block", "previousLowestBlock", previousLowestBlock, "lowestBlock", lowestBlock, "anchor", anchor.Name)
+	}
+
+	// a backend consistently anchoring the consensus below the highest block caps the head of the group;
+	// when several backends are at the lowest block, the freshest one is counted
+	if highestBlock, _ := cp.GetHighestBlock(); lowestBlock < highestBlock {
+		RecordConsensusBackendAnchor(cp.backendGroup, anchor)
+	}
+
+	if lowestBlock > currentConsensusBlockNumber {
+		cp.logger.Info("validating consensus on block", "lowestBlock", lowestBlock)
+	}
+
+	proposedBlock := lowestBlock
+	agreement, err := cp.checkBlockAgreement(ctx, proposedBlock, lowestBlockHash, currentConsensusBlockNumber, prefetched)
+	if err != nil {
+		cp.logger.Warn("error validating consensus", "err", err)
+		return nil
+	}
+	broken, breaker := agreement.broken, agreement.breaker
+
+	// the rewind doesn't go further than maxBlockRange blocks below the lowest block
+	var floor hexutil.Uint64
+	if cp.maxBlockRange > 0 && uint64(lowestBlock) > cp.maxBlockRange {
+		floor = lowestBlock - hexutil.Uint64(cp.maxBlockRange)
+	}
+
+	if !agreement.agreed {
+		if cp.rewindStrategy == RewindBatched {
+			proposedBlock, agreement, err = cp.searchBlockAgreement(ctx, proposedBlock, floor, currentConsensusBlockNumber)
+		} else {
+			proposedBlock, agreement, err = cp.walkBlockAgreement(ctx, proposedBlock, floor, currentConsensusBlockNumber)
+		}
+		if err != nil {
+			cp.logger.Warn("error validating consensus", "err", err)
+			return nil
+		}
+		if agreement == nil {
+			return nil
+		}
+		if !broken {
+			broken, breaker = agreement.broken, agreement.breaker
+		}
+	}
+
+	return &consensusProposal{
+		blockNumber:      proposedBlock,
+		blockHash:        agreement.hash,
+		timestamp:        agreement.timestamp,
+		backends:         agreement.backends,
+		filteredBackends: agreement.filtered,
+		broken:           broken,
+		breaker:          breaker,
+	}
+}
+
+// lowestBlock returns the lowest latest block across the eligible backends, its hash,
+// and the backend reporting it. Among the backends at the lowest block, the one whose state
+// was observed the most recently is authoritative for the hash, to favor the freshest view
+func (cp *ConsensusPoller) lowestBlock(eligible func(be *Backend) bool) (hexutil.Uint64, string, *Backend) {
+	var lowestBlock hexutil.Uint64
+	var lowestBlockHash string
+	var anchor *Backend
+	var anchorUpdate time.Time
+	for _, be := range cp.backendGroup.Backends {
+		if !eligible(be) {
+			continue
+		}
+		backendLatestBlockNumber, backendLatestBlockHash := cp.getBackendState(be)
+		lastUpdate := cp.getLastUpdate(be)
+		if lowestBlock == 0 || backendLatestBlockNumber < lowestBlock ||
+			(backendLatestBlockNumber == lowestBlock && lastUpdate.After(anchorUpdate)) {
+			lowestBlock = backendLatestBlockNumber
+			lowestBlockHash = backendLatestBlockHash
+			anchor = be
+			anchorUpdate = lastUpdate
+		}
+	}
+	return lowestBlock, lowestBlockHash, anchor
+}
+
+// getLastUpdate returns when the state of the backend was last updated
+func (cp *ConsensusPoller) getLastUpdate(be *Backend) time.Time {
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	defer bs.backendStateMux.Unlock()
+	return bs.lastUpdate
+}
+
+// blockAgreement is the outcome of checking whether the voting backends agree on a block
+type blockAgreement struct {
+	agreed bool
+	hash   string
+	// timestamp is the timestamp of the agreed block, zero when it was fetched from none of the voters
+	timestamp uint64
+	backends  []*Backend
+	filtered  []string
+	// broken is set when a backend disagrees on a block at or below the current consensus, breaker is that backend
+	broken  bool
+	breaker string
+}
+
+// checkBlockAgreement fetches the block from the voting backends, and checks that all of them agree on it.
+// The expected hash is, when empty, the hash served by the backend whose state was observed the most recently
+func (cp *ConsensusPoller) checkBlockAgreement(ctx context.Context, proposedBlock hexutil.Uint64, proposedBlockHash string, currentConsensusBlockNumber hexutil.Uint64, prefetched map[*Backend]blockResult) (*blockAgreement, error) {
+	var proposedBlockTxs []string
+	agreement := &blockAgreement{
+		agreed:   true,
+		backends: make([]*Backend, 0, len(cp.backendGroup.Backends)),
+		filtered: make([]string, 0, len(cp.backendGroup.Backends)),
+	}
+
+	voters := make([]*Backend, 0, len(cp.backendGroup.Backends))
+	cachedStates := make(map[*Backend]bool)
+	for _, be := range cp.backendGroup.Backends {
+		filtered, useCachedState := cp.isFiltered(be)
+		if filtered {
+			agreement.filtered = append(agreement.filtered, be.Name)
+			continue
+		}
+		if useCachedState {
+			// the cached state only vouches for the latest block of the backend
+			if blockNumber, _ := cp.getBackendState(be); blockNumber != proposedBlock {
+				agreement.filtered = append(agreement.filtered, be.Name)
+				continue
+			}
+			cachedStates[be] = true
+		}
+		voters = append(voters, be)
+	}
+
+	results := make([]blockResult, len(voters))
+	err := cp.runConcurrently(ctx, len(voters), func(i int) {
+		be, res := voters[i], &results[i]
+		if r, ok := prefetched[be]; ok && r.number == proposedBlock && !cp.verifyTransactions {
+			*res = r
+			return
+		}
+		switch {
+		case cachedStates[be]:
+			res.number, res.hash = cp.getBackendState(be)
+		case cp.verifyTransactions:
+			*res = cp.fetchBlockWithTxs(ctx, be, proposedBlock.String())
+		default:
+			*res = cp.fetchBlockResult(ctx, be, proposedBlock.String())
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if proposedBlockHash == "" {
+		if i := cp.freshestResult(voters, results); i >= 0 {
+			proposedBlockHash = results[i].hash
+			if !cachedStates[voters[i]] {
+				proposedBlockTxs = results[i].txs
+			}
+		}
+	}
+
+	for i, be := range voters {
+		actualBlockNumber, actualBlockHash, actualBlockTxs := results[i].number, results[i].hash, results[i].txs
+		if results[i].err != nil {
+			cp.logger.Warn("error updating backend", "name", be.Name, "err", results[i].err)
+			continue
+		}
+		if cachedStates[be] {
+			actualBlockTxs = proposedBlockTxs
+		}
+		if proposedBlockHash == "" {
+			proposedBlockHash = actualBlockHash
+		}
+		if proposedBlockTxs == nil {
+			proposedBlockTxs = actualBlockTxs
+		}
+		blocksDontMatch := (actualBlockNumber != proposedBlock) || (actualBlockHash != proposedBlockHash) ||
+			(cp.verifyTransactions && !equalStrings(actualBlockTxs, proposedBlockTxs))
+		if blocksDontMatch {
+			if currentConsensusBlockNumber >= actualBlockNumber {
+				cp.logger.Warn("backend broke consensus", "name", be.Name, "blockNum", actualBlockNumber, "proposedBlockNum", proposedBlock, "blockHash", actualBlockHash, "proposedBlockHash", proposedBlockHash)
+				agreement.broken = true
+				agreement.breaker = be.Name
+			}
+			agreement.agreed = false
+			break
+		}
+		agreement.backends = append(agreement.backends, be)
+	}
+	agreement.hash = proposedBlockHash
+	agreement.timestamp = agreedTimestamp(results, proposedBlockHash)
+
+	if agreement.agreed && cp.strictChaining {
+		var unchained []string
+		agreement.backends, unchained = cp.filterUnchained(ctx, proposedBlock, agreement.backends, parentHashesOf(voters, results))
+		agreement.filtered = append(agreement.filtered, unchained...)
+	}
+
+	// an agreement needs at least a backend serving the block, i.e. not when all of them errored out
+	if agreement.agreed && len(agreement.backends) == 0 {
+		return nil, fmt.Errorf("no backend validated block %d", proposedBlock)
+	}
+
+	return agreement, nil
+}
+
+// agreedTimestamp returns the timestamp of the first block fetched with the agreed hash, zero when there is none
+func agreedTimestamp(results []blockResult, blockHash string) uint64 {
+	for _, res := range results {
+		if res.err == nil && res.hash == blockHash && res.timestamp != 0 {
+			return res.timestamp
+		}
+	}
+	return 0
+}
+
+// freshestResult returns the index of the successful result from the backend whose state was observed
+// the most recently, the first one on a tie, or -1 when all the results are errors
+func (cp *ConsensusPoller) freshestResult(voters []*Backend, results []blockResult) int {
+	freshest := -1
+	var freshestUpdate time.Time
+	for i, be := range voters {
+		if results[i].err != nil {
+			continue
+		}
+		if lastUpdate := cp.getLastUpdate(be); freshest < 0 || lastUpdate.After(freshestUpdate) {
+			freshest = i
+			freshestUpdate = lastUpdate
+		}
+	}
+	return freshest
+}
+
+// walkBlockAgreement walks back one block at a time from the disagreed block, until the backends agree.
+// It returns a nil agreement when there is none down to the floor block
+func (cp *ConsensusPoller) walkBlockAgreement(ctx context.Context, disagreed hexutil.Uint64, floor hexutil.Uint64, currentConsensusBlockNumber hexutil.Uint64) (hexutil.Uint64, *blockAgreement, error) {
+	broken, breaker := false, ""
+	for proposedBlock := disagreed; ; {
+		// the block number is unsigned, there is nothing to walk back to from genesis, nor from below the floor
+		if proposedBlock <= floor {
+			cp.logNoAgreement(floor)
+			return 0, nil, nil
+		}
+		// walk one block behind and try again
+		proposedBlock -= 1
+		cp.logger.Info("no consensus, now trying", "block:", proposedBlock)
+		agreement, err := cp.checkBlockAgreement(ctx, proposedBlock, "", currentConsensusBlockNumber, nil)
+		if err != nil {
+			return 0, nil, err
+		}
+		if !broken {
+			broken, breaker = agreement.broken, agreement.breaker
+		}
+		if agreement.agreed {
+			agreement.broken, agreement.breaker = broken, breaker
+			return proposedBlock, agreement, nil
+		}
+	}
+}
+
+// searchBlockAgreement looks for the highest block below the disagreed block where the backends agree,
+// jumping back by doubling steps until they agree, then bisecting between the lowest disagreed block
+// and the agreed one. It returns a nil agreement when there is none down to the floor block
+func (cp *ConsensusPoller) searchBlockAgreement(ctx context.Context, disagreed hexutil.Uint64, floor hexutil.Uint64, currentConsensusBlockNumber hexutil.Uint64) (hexutil.Uint64, *blockAgreement, error) {
+	broken, breaker := false, ""
+	check := func(block hexutil.Uint64) (*blockAgreement, error) {
+		cp.logger.Info("no consensus, now trying", "block:", block)
+		agreement, err := cp.checkBlockAgreement(ctx, block, "", currentConsensusBlockNumber, nil)
+		if err != nil {
+			return nil, err
+		}
+		if !broken {
+			broken, breaker = agreement.broken, agreement.breaker
+		}
+		return agreement, nil
+	}
+
+	high := disagreed
+	var low hexutil.Uint64
+	var lowAgreement *blockAgreement
+	for step := hexutil.Uint64(1); lowAgreement == nil; step *= 2 {
+		if high <= floor {
+			cp.logNoAgreement(floor)
+			return 0, nil, nil
+		}
+		// high is above the floor here, so the candidate doesn't underflow
+		candidate := floor
+		if high-floor > step {
+			candidate = high - step
+		}
+		agreement, err := check(candidate)
+		if err != nil {
+			return 0, nil, err
+		}
+		if agreement.agreed {
+			low, lowAgreement = candidate, agreement
+		} else {
+			high = candidate
+		}
+	}
+
+	for high-low > 1 {
+		mid := low + (high-low)/2
+		agreement, err := check(mid)
+		if err != nil {
+			return 0, nil, err
+		}
+		if agreement.agreed {
+			low, lowAgreement = mid, agreement
+		} else {
+			high = mid
+		}
+	}
+
+	lowAgreement.broken, lowAgreement.breaker = broken, breaker
+	return low, lowAgreement, nil
+}
+
+func (cp *ConsensusPoller) logNoAgreement(floor hexutil.Uint64) {
+	if floor == 0 {
+		cp.logger.Warn("no consensus down to genesis")
+	} else {
+		cp.logger.Warn("no consensus within the max block range", "floorBlock", floor)
+	}
+}
+
+// proposeQuorumConsensus picks the highest block where a quorum of backends agree on the block hash.
+// The walk starts at the highest block a quorum of backends reached, so a backend far ahead of the others
+// costs no fetch, and goes no further than maxBlockRange blocks below it
+func (cp *ConsensusPoller) proposeQuorumConsensus(ctx context.Context, currentConsensusBlockNumber hexutil.Uint64) *consensusProposal {
+	var highestBlock hexutil.Uint64
+	candidates := make([]*Backend, 0, len(cp.backendGroup.Backends))
+	latestBlocks := make(map[*Backend]hexutil.Uint64, len(cp.backendGroup.Backends))
+	filteredBackendsNames := make([]string, 0, len(cp.backendGroup.Backends))
+	cachedStates := make(map[*Backend]string)
+
+	for _, be := range cp.backendGroup.Backends {
+		filtered, useCachedState := cp.isFiltered(be)
+		if filtered {
+			filteredBackendsNames = append(filteredBackendsNames, be.Name)
+			continue
+		}
+		backendLatestBlockNumber, backendLatestBlockHash := cp.getBackendState(be)
+		if backendLatestBlockNumber == 0 {
+			continue
+		}
+		if useCachedState {
+			cachedStates[be] = backendLatestBlockHash
+		}
+		candidates = append(candidates, be)
+		latestBlocks[be] = backendLatestBlockNumber
+		if backendLatestBlockNumber > highestBlock {
+			highestBlock = backendLatestBlockNumber
+		}
+	}
+
+	quorum := cp.quorumSize()
+	if len(candidates) < quorum {
+		if highestBlock > 0 {
+			cp.logger.Warn("not enough backends to reach quorum", "candidates", len(candidates), "quorum", quorum)
+		}
+		return nil
+	}
+
+	// the quorum-th highest latest block is the highest block a quorum of backends can agree on
+	heads := make([]hexutil.Uint64, 0, len(candidates))
+	for _, be := range candidates {
+		heads = append(heads, latestBlocks[be])
+	}
+	sort.Slice(heads, func(i, j int) bool { return heads[i] > heads[j] })
+	startBlock := heads[quorum-1]
+	var floor hexutil.Uint64
+	if cp.maxBlockRange > 0 && uint64(startBlock) > cp.maxBlockRange {
+		floor = startBlock - hexutil.Uint64(cp.maxBlockRange)
+	}
+
+	for proposedBlock := startBlock; proposedBlock > 0 && proposedBlock >= floor; proposedBlock-- {
+		voters := make([]*Backend, 0, len(candidates))
+		for _, be := range candidates {
+			if latestBlocks[be] < proposedBlock {
+				continue
+			}
+			// the cached state only vouches for the latest block of the backend
+			if _, ok := cachedStates[be]; ok && latestBlocks[be] != proposedBlock {
+				continue
+			}
+			voters = append(voters, be)
+		}
+		// not enough backends can vouch for the block, there is no quorum to fetch it for
+		if len(voters) < quorum {
+			continue
+		}
+
+		results := make([]blockResult, len(voters))
+		err := cp.runConcurrently(ctx, len(voters), func(i int) {
+			be, res := voters[i], &results[i]
+			if cachedHash, ok := cachedStates[be]; ok {
+				res.number, res.hash = proposedBlock, cachedHash
+				return
+			}
+			*res = cp.fetchBlockResult(ctx, be, proposedBlock.String())
+		})
+		if err != nil {
+			cp.logger.Warn("error validating consensus", "err", err)
+			return nil
+		}
+
+		clusters := make(map[string][]*Backend)
+		clusterHashes := make([]string, 0)
+		for i, be := range voters {
+			actualBlockNumber, actualBlockHash := results[i].number, results[i].hash
+			if results[i].err != nil {
+				cp.logger.Warn("error updating backend", "name", be.Name, "err", results[i].err)
+				continue
+			}
+			if actualBlockNumber != proposedBlock {
+				continue
+			}
+			if _, ok := clusters[actualBlockHash]; !ok {
+				clusterHashes = append(clusterHashes, actualBlockHash)
+			}
+			clusters[actualBlockHash] = append(clusters[actualBlockHash], be)
+		}
+
+		if cp.splitBrainDetection {
+			quorumHashes := make([]string, 0, len(clusterHashes))
+			for _, h := range clusterHashes {
+				if len(clusters[h]) >= quorum {
+					quorumHashes = append(quorumHashes, h)
+				}
+			}
+			if len(quorumHashes) > 1 {
+				cp.setSplitBrain(true, proposedBlock, quorumHashes)
+				return nil
+			}
+		}
+
+		proposedBlockHash := pluralityHash(clusters, clusterHashes)
+
+		if len(clusters[proposedBlockHash]) >= quorum {
+			if cp.splitBrainDetection {
+				cp.setSplitBrain(false, proposedBlock, nil)
+			}
+			broken := len(clusterHashes) > 1 && currentConsensusBlockNumber >= proposedBlock
+			if broken {
+				cp.logger.Warn("backends broke consensus", "blockNum", proposedBlock, "blockHash", proposedBlockHash, "hashes", len(clusterHashes))
+			}
+			proposal := &consensusProposal{
+				blockNumber:      proposedBlock,
+				blockHash:        proposedBlockHash,
+				timestamp:        agreedTimestamp(results, proposedBlockHash),
+				backends:         clusters[proposedBlockHash],
+				filteredBackends: filteredBackendsNames,
+				broken:           broken,
+			}
+			if broken {
+				proposal.breaker = minorityBackend(clusters, clusterHashes, proposedBlockHash)
+			}
+			if cp.strictChaining {
+				cp.filterUnchainedProposal(ctx, proposal, voters, results)
+				// the unchained backends don't count toward the quorum
+				if len(proposal.backends) < quorum {
+					cp.logger.Info("no quorum of chained backends, now trying", "block", proposedBlock-1)
+					continue
+				}
+			}
+			return proposal
+		}
+		cp.logger.Info("no quorum, now trying", "block", proposedBlock-1)
+	}
+
+	cp.logNoAgreement(floor)
+	return nil
+}
+
+// proposeSingleBackendConsensus proposes the latest block of the first backend of the group as is. There is
+// nothing to compare it against, so it never breaks the consensus, i.e. when the backend reorgs
+func (cp *ConsensusPoller) proposeSingleBackendConsensus() *consensusProposal {
+	be := cp.backendGroup.Backends[0]
+	if filtered, _ := cp.isFiltered(be); filtered {
+		return nil
+	}
+	blockNumber, blockHash := cp.getBackendState(be)
+	if blockNumber == 0 {
+		return nil
+	}
+	return &consensusProposal{
+		blockNumber:      blockNumber,
+		blockHash:        blockHash,
+		backends:         []*Backend{be},
+		filteredBackends: []string{},
+	}
+}
+
+// proposeHeadOnlyConsensus proposes the lowest head number of the available backends, all of them in the
+// consensus group, without any hash to validate nor block to rewind to
+func (cp *ConsensusPoller) proposeHeadOnlyConsensus() *consensusProposal {
+	proposal := &consensusProposal{
+		backends:         make([]*Backend, 0, len(cp.backendGroup.Backends)),
+		filteredBackends: make([]string, 0, len(cp.backendGroup.Backends)),
+	}
+	for _, be := range cp.backendGroup.Backends {
+		if filtered, _ := cp.isFiltered(be); filtered {
+			proposal.filteredBackends = append(proposal.filteredBackends, be.Name)
+			continue
+		}
+		blockNumber, _ := cp.getBackendState(be)
+		if blockNumber == 0 {
+			continue
+		}
+		if len(proposal.backends) == 0 || blockNumber < proposal.blockNumber {
+			proposal.blockNumber = blockNumber
+		}
+		proposal.backends = append(proposal.backends, be)
+	}
+	if len(proposal.backends) == 0 {
+		return nil
+	}
+	return proposal
+}
+
+// proposeWeightedMedianConsensus picks the weighted median of the backends latest blocks, i.e. the lowest
+// block reached by at least half of the total weight, and groups the backends agreeing on its hash
+func (cp *ConsensusPoller) proposeWeightedMedianConsensus(ctx context.Context, currentConsensusBlockNumber hexutil.Uint64) *consensusProposal {
+	candidates := make([]*Backend, 0, len(cp.backendGroup.Backends))
+	latestBlocks := make(map[*Backend]hexutil.Uint64, len(cp.backendGroup.Backends))
+	filteredBackendsNames := make([]string, 0, len(cp.backendGroup.Backends))
+	cachedStates := make(map[*Backend]string)
+	totalWeight := 0.0
+
+	for _, be := range cp.backendGroup.Backends {
+		filtered, useCachedState := cp.isFiltered(be)
+		if filtered {
+			filteredBackendsNames = append(filteredBackendsNames, be.Name)
+			continue
+		}
+		backendLatestBlockNumber, backendLatestBlockHash := cp.getBackendState(be)
+		if backendLatestBlockNumber == 0 {
+			continue
+		}
+		if useCachedState {
+			cachedStates[be] = backendLatestBlockHash
+		}
+		candidates = append(candidates, be)
+		latestBlocks[be] = backendLatestBlockNumber
+		totalWeight += cp.votingWeight(be)
+	}
+
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	sorted := make([]*Backend, len(candidates))
+	copy(sorted, candidates)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return latestBlocks[sorted[i]] < latestBlocks[sorted[j]]
+	})
+	var medianBlock hexutil.Uint64
+	cumulativeWeight := 0.0
+	for _, be := range sorted {
+		cumulativeWeight += cp.votingWeight(be)
+		if 2*cumulativeWeight >= totalWeight {
+			medianBlock = latestBlocks[be]
+			break
+		}
+	}
+
+	voters := make([]*Backend, 0, len(candidates))
+	for _, be := range candidates {
+		if latestBlocks[be] < medianBlock {
+			continue
+		}
+		// the cached state only vouches for the latest block of the backend
+		if _, ok := cachedStates[be]; ok && latestBlocks[be] != medianBlock {
+			continue
+		}
+		voters = append(voters, be)
+	}
+
+	results := make([]blockResult, len(voters))
+	err := cp.runConcurrently(ctx, len(voters), func(i int) {
+		be, res := voters[i], &results[i]
+		if cachedHash, ok := cachedStates[be]; ok {
+			res.number, res.hash = medianBlock, cachedHash
+			return
+		}
+		*res = cp.fetchBlockResult(ctx, be, medianBlock.String())
+	})
+	if err != nil {
+		cp.logger.Warn("error validating consensus", "err", err)
+		return nil
+	}
+
+	clusters := make(map[string][]*Backend)
+	clusterHashes := make([]string, 0)
+	for i, be := range voters {
+		if results[i].err != nil {
+			cp.logger.Warn("error updating backend", "name", be.Name, "err", results[i].err)
+			continue
+		}
+		if results[i].number != medianBlock {
+			continue
+		}
+		if _, ok := clusters[results[i].hash]; !ok {
+			clusterHashes = append(clusterHashes, results[i].hash)
+		}
+		clusters[results[i].hash] = append(clusters[results[i].hash], be)
+	}
+	if len(clusterHashes) == 0 {
+		return nil
+	}
+
+	proposedBlockHash := pluralityHash(clusters, clusterHashes)
+	broken := len(clusterHashes) > 1 && currentConsensusBlockNumber >= medianBlock
+	if broken {
+		cp.logger.Warn("backends broke consensus", "blockNum", medianBlock, "blockHash", proposedBlockHash, "hashes", len(clusterHashes))
+	}
+	proposal := &consensusProposal{
+		blockNumber:      medianBlock,
+		blockHash:        proposedBlockHash,
+		timestamp:        agreedTimestamp(results, proposedBlockHash),
+		backends:         clusters[proposedBlockHash],
+		filteredBackends: filteredBackendsNames,
+		broken:           broken,
+	}
+	if broken {
+		proposal.breaker = minorityBackend(clusters, clusterHashes, proposedBlockHash)
+	}
+	if cp.strictChaining {
+		cp.filterUnchainedProposal(ctx, proposal, voters, results)
+		if len(proposal.backends) == 0 {
+			return nil
+		}
+	}
+	return proposal
+}
+
+// filterUnchainedProposal moves the backends of the proposal whose block doesn't link to the agreed parent
+// to its filtered backends, from the blocks the voters were fetched
+func (cp *ConsensusPoller) filterUnchainedProposal(ctx context.Context, proposal *consensusProposal, voters []*Backend, results []blockResult) {
+	var unchained []string
+	proposal.backends, unchained = cp.filterUnchained(ctx, proposal.blockNumber, proposal.backends, parentHashesOf(voters, results))
+	proposal.filteredBackends = append(proposal.filteredBackends, unchained...)
+}
+
+// parentHashesOf maps the voters to the parent hash of the block fetched from each of them
+func parentHashesOf(voters []*Backend, results []blockResult) map[*Backend]string {
+	parentHashes := make(map[*Backend]string, len(voters))
+	for i, be := range voters {
+		parentHashes[be] = results[i].parentHash
+	}
+	return parentHashes
+}
+
+// backendWeight returns the weight of the backend in weighted consensus modes, defaulting to 1
+func backendWeight(be *Backend) int {
+	if be.weight > 0 {
+		return be.weight
+	}
+	return 1
+}
+
+// WithSplitBrainDetection refuses to pick a winner in quorum mode when more than one hash cluster meets
+// the quorum at the same height, i.e. on a network partition with a quorum below the majority. The group
+// is flagged split brain and fails closed, whatever the fail mode, until a single cluster meets the quorum
+func WithSplitBrainDetection() ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.splitBrainDetection = true
+	}
+}
+
+// WithReliabilityHalfLife decays the reliability score with time on top of the later polls, halving the
+// impact of a failed or diverging poll every halfLife, so recent divergences count more than old ones and
+// a backend recovers its score after a bad period
+func WithReliabilityHalfLife(halfLife time.Duration) ConsensusOpt {
+	return func(cp *ConsensusPoller) {
+		cp.reliabilityHalfLife = halfLife
+	}
+}
+
+// votingWeight returns the weight of the backend in weighted median mode, scaled by its reliability score
+// when reliability weighting is enabled
+func (cp *ConsensusPoller) votingWeight(be *Backend) float64 {
+	weight := float64(backendWeight(be))
+	if cp.reliabilityWeighting {
+		weight *= cp.GetBackendReliability(be)
+	}
+	return weight
+}
+
+// blockResult is the outcome of fetching a block from a backend
+type blockResult struct {
+	number     hexutil.Uint64
+	hash       string
+	parentHash string
+	// timestamp is zero when the block was not fetched, i.e. served from the cached state, or has no timestamp
+	timestamp uint64
+	txs       []string
+	err       error
+}
+
+// runConcurrently calls fn for each index in [0, n) on the poller worker pool, and waits for all of them
+func (cp *ConsensusPoller) runConcurrently(ctx context.Context, n int, fn func(i int)) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for i := 0; i < n; i++ {
+		if err := cp.workers.Acquire(ctx, 1); err != nil {
+			return err
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer cp.workers.Release(1)
+			fn(i)
+		}(i)
+	}
+	return nil
+}
+
+// pluralityHash returns the hash shared by the most backends. Ties are broken by picking the
+// lexicographically smallest hash, so the outcome doesn't depend on the backend order
+func pluralityHash(clusters map[string][]*Backend, clusterHashes []string) string {
+	var hash string
+	for _, h := range clusterHashes {
+		if hash == "" || len(clusters[h]) > len(clusters[hash]) ||
+			(len(clusters[h]) == len(clusters[hash]) && h < hash) {
+			hash = h
+		}
+	}
+	return hash
+}
+
+// filterUnchained keeps the backends agreeing on a block whose parent hash, from the blocks fetched to check the
+// agreement, is the hash of the block agreed on at the height below. The others are returned as unchained. A block
+// without a known parent hash, i.e. served from the cached state, is kept, as are all of them when the block below
+// can't be fetched
+func (cp *ConsensusPoller) filterUnchained(ctx context.Context, blockNumber hexutil.Uint64, backends []*Backend, parentHashes map[*Backend]string) ([]*Backend, []string) {
+	if blockNumber == 0 {
+		return backends, nil
+	}
+	linked := make([]*Backend, 0, len(backends))
+	for _, be := range backends {
+		if parentHashes[be] != "" {
+			linked = append(linked, be)
+		}
+	}
+	if len(linked) == 0 {
+		return backends, nil
+	}
+	agreedParentHash, ok := cp.agreedBlockHash(ctx, blockNumber-1, linked)
+	if !ok {
+		return backends, nil
+	}
+
+	chained := make([]*Backend, 0, len(backends))
+	unchained := make([]string, 0)
+	for _, be := range backends {
+		if parentHash := parentHashes[be]; parentHash != "" && parentHash != agreedParentHash {
+			cp.logger.Warn("backend broke consensus chaining", "name", be.Name, "blockNum", blockNumber, "parentHash", parentHash, "agreedParentHash", agreedParentHash)
+			cp.resetStableCycles(be)
+			cp.recordReliability(be, true)
+			unchained = append(unchained, be.Name)
+			continue
+		}
+		chained = append(chained, be)
+	}
+	return chained, unchained
+}
+
+// agreedBlockHash returns the hash most of the backends serve for the block, and false when none of them served it
+func (cp *ConsensusPoller) agreedBlockHash(ctx context.Context, blockNumber hexutil.Uint64, backends []*Backend) (string, bool) {
+	results := make([]blockResult, len(backends))
+	err := cp.runConcurrently(ctx, len(backends), func(i int) {
+		results[i] = cp.fetchBlockResult(ctx, backends[i], blockNumber.String())
+	})
+	if err != nil {
+		cp.logger.Warn("error fetching the agreed block", "blockNum", blockNumber, "err", err)
+		return "", false
+	}
+
+	clusters := make(map[string][]*Backend)
+	clusterHashes := make([]string, 0)
+	for i, be := range backends {
+		if results[i].err != nil || results[i].number != blockNumber {
+			continue
+		}
+		if _, ok := clusters[results[i].hash]; !ok {
+			clusterHashes = append(clusterHashes, results[i].hash)
+		}
+		clusters[results[i].hash] = append(clusters[results[i].hash], be)
+	}
+	if len(clusterHashes) == 0 {
+		return "", false
+	}
+	return pluralityHash(clusters, clusterHashes), true
+}
+
+// minorityBackend returns the name of the first backend outside of the cluster of the given hash
+func minorityBackend(clusters map[string][]*Backend, clusterHashes []string, hash string) string {
+	for _, h := range clusterHashes {
+		if h != hash && len(clusters[h]) > 0 {
+			return clusters[h][0].Name
+		}
+	}
+	return ""
+}
+
+// quorumSize returns the number of backends required to agree in quorum mode,
+// defaulting to a majority of the backend group
+func (cp *ConsensusPoller) quorumSize() int {
+	if cp.quorum > 0 {
+		return cp.quorum
+	}
+	return len(cp.backendGroup.Backends)/2 + 1
+}
+
+// isFiltered returns true if the backend can't take part in the consensus in this cycle,
+// and whether a rate-limited backend takes part with its cached state instead of being polled
+func (cp *ConsensusPoller) isFiltered(be *Backend) (bool, bool) {
+	rateLimited := be.IsRateLimited()
+	if !be.Online() || cp.isBanned(be) || cp.isExcludedFromVoting(be) || cp.isCircuitOpen(be) {
+		return true, false
+	}
+	if rateLimited {
+		if cp.hasFreshState(be) {
+			return false, true
+		}
+		return true, false
+	}
+	return false, false
+}
+
+// hasFreshState returns true if the cached state of the backend is recent enough
+// to count toward the consensus while the backend is rate limited
+func (cp *ConsensusPoller) hasFreshState(be *Backend) bool {
+	if cp.rateLimitedStateMaxAge == 0 {
+		return false
+	}
+	bs := cp.backendState[be]
+	defer bs.backendStateMux.Unlock()
+	bs.backendStateMux.Lock()
+	return !bs.lastUpdate.IsZero() && time.Since(bs.lastUpdate) <= cp.rateLimitedStateMaxAge
+}
+
+// sampleGroupStateLog returns true if the group state must be logged, i.e. it changed
+// since the last log or the log interval has elapsed
+func (cp *ConsensusPoller) sampleGroupStateLog(blockNumber hexutil.Uint64, consensusGroup string, filteredGroup string, broken bool) bool {
+	last := &cp.lastGroupStateLog
+	last.cyclesSinceLast++
+
+	changed := broken ||
+		last.blockNumber != blockNumber ||
+		last.consensusGroup != consensusGroup ||
+		last.filteredGroup != filteredGroup
+	if !changed && last.cyclesSinceLast < cp.groupStateLogInterval {
+		return false
+	}
+
+	*last = groupStateLog{
+		blockNumber:    blockNumber,
+		consensusGroup: consensusGroup,
+		filteredGroup:  filteredGroup,
+	}
+	return true
+}
+
+// fetchBlock Convenient wrapper to make a request to get a block directly from the backend
+func (cp *ConsensusPoller) fetchBlock(ctx context.Context, be *Backend, block string) (blockNumber hexutil.Uint64, blockHash string, parentHash string, err error) {
+	jsonMap, err := cp.requestBlock(ctx, be, block, false)
+	if err != nil {
+		return 0, "", "", err
+	}
+	return cp.parseBlock(be, jsonMap)
+}
+
+// fetchBlockResult is like fetchBlock, but also returns the timestamp of the block, zero when not reported
+func (cp *ConsensusPoller) fetchBlockResult(ctx context.Context, be *Backend, block string) (res blockResult) {
+	jsonMap, err := cp.requestBlock(ctx, be, block, false)
+	if err != nil {
+		res.err = err
+		return
+	}
+	if res.number, res.hash, res.parentHash, res.err = cp.parseBlock(be, jsonMap); res.err == nil {
+		res.timestamp = blockTimestamp(jsonMap)
+	}
+	return
+}
+
+// fetchBlockWithTxs is like fetchBlockResult, but also returns the hashes of the transactions included in the block
+func (cp *ConsensusPoller) fetchBlockWithTxs(ctx context.Context, be *Backend, block string) (res blockResult) {
+	jsonMap, err := cp.requestBlock(ctx, be, block, true)
+	if err != nil {
+		res.err = err
+		return
+	}
+	if res.number, res.hash, res.parentHash, res.err = cp.parseBlock(be, jsonMap); res.err != nil {
+		return
+	}
+	res.timestamp = blockTimestamp(jsonMap)
+
+	txs, ok := jsonMap["transactions"].([]interface{})
+	if !ok {
+		return blockResult{err: fmt.Errorf("unexpected transactions type checking consensus on backend %s", be.Name)}
+	}
+	res.txs = make([]string, 0, len(txs))
+	for _, tx := range txs {
+		switch tx := tx.(type) {
+		case string:
+			txHash, err := cp.normalizeBlockID(tx)
+			if err != nil {
+				return blockResult{err: fmt.Errorf("unexpected transaction hash checking consensus on backend %s: %w", be.Name, err)}
+			}
+			res.txs = append(res.txs, txHash)
+		case map[string]interface{}:
+			txHash, ok := tx["hash"].(string)
+			if !ok {
+				return blockResult{err: fmt.Errorf("unexpected transaction hash type checking consensus on backend %s", be.Name)}
+			}
+			if txHash, err = cp.normalizeBlockID(txHash); err != nil {
+				return blockResult{err: fmt.Errorf("unexpected transaction hash checking consensus on backend %s: %w", be.Name, err)}
+			}
+			res.txs = append(res.txs, txHash)
+		default:
+			return blockResult{err: fmt.Errorf("unexpected transaction type checking consensus on backend %s", be.Name)}
+		}
+	}
+	return
+}
+
+// blockTimestamp returns the timestamp of a fetched block, zero when not reported
+func blockTimestamp(jsonMap map[string]interface{}) uint64 {
+	if t, err := parseQuantity(jsonMap["timestamp"]); err == nil {
+		return uint64(t)
+	}
+	return 0
+}
+
+// fetchLatestBlock returns the latest block of the backend, or its unsafe L2 head when polling the sync status.
+// In finalized only mode, it returns the finalized block, or the finalized L2 head, instead
+func (cp *ConsensusPoller) fetchLatestBlock(ctx context.Context, be *Backend) (blockNumber hexutil.Uint64, blockHash string, err error) {
+	blockNumber, blockHash, _, err = cp.fetchHead(ctx, be)
+	return
+}
+
+// fetchHead is like fetchLatestBlock, but also returns the timestamp of the block, zero when not reported
+func (cp *ConsensusPoller) fetchHead(ctx context.Context, be *Backend) (blockNumber hexutil.Uint64, blockHash string, timestamp uint64, err error) {
+	// the head only mode needs the head number alone, unless the head is another block than the latest one
+	if cp.mode == ConsensusModeHeadOnly && !cp.syncStatusHeads && !cp.finalizedOnly && be.consensusBlockTag("latest") == "latest" {
+		var rpcRes RPCRes
+		if err := cp.pollRPC(ctx, be, &rpcRes, "eth_blockNumber"); err != nil {
+			return 0, "", 0, err
+		}
+		blockNumber, err := parseQuantity(rpcRes.Result)
+		if err != nil {
+			return 0, "", 0, fmt.Errorf("unexpected block number on backend %s: %w", be.Name, err)
+		}
+		return blockNumber, "", 0, nil
+	}
+	if !cp.syncStatusHeads {
+		tag := "latest"
+		if cp.finalizedOnly {
+			tag = "finalized"
+		}
+		jsonMap, err := cp.requestBlock(ctx, be, tag, false)
+		if err != nil {
+			return 0, "", 0, err
+		}
+		blockNumber, blockHash, _, err = cp.parseBlock(be, jsonMap)
+		if err != nil {
+			return 0, "", 0, err
+		}
+		return blockNumber, blockHash, blockTimestamp(jsonMap), nil
+	}
+	status, err := cp.fetchSyncStatus(ctx, be)
+	if err != nil {
+		return 0, "", 0, err
+	}
+	head, name := status.UnsafeL2, "unsafe"
+	if cp.finalizedOnly {
+		head, name = status.FinalizedL2, "finalized"
+	}
+	blockHash, err = cp.normalizeBlockID(head.Hash)
+	if err != nil {
+		return 0, "", 0, fmt.Errorf("unexpected %s head on backend %s: %w", name, be.Name, err)
+	}
+	return hexutil.Uint64(head.Number), blockHash, head.Timestamp, nil
+}
+
+// syncStatus holds the L2 heads of an optimism_syncStatus response
+type syncStatus struct {
+	UnsafeL2    l2BlockRef `json:"unsafe_l2"`
+	SafeL2      l2BlockRef `json:"safe_l2"`
+	FinalizedL2 l2BlockRef `json:"finalized_l2"`
+}
+
+type l2BlockRef struct {
+	Hash      string `json:"hash"`
+	Number    uint64 `json:"number"`
+	Timestamp uint64 `json:"timestamp"`
+}
+
+func (cp *ConsensusPoller) fetchSyncStatus(ctx context.Context, be *Backend) (*syncStatus, error) {
+	var rpcRes RPCRes
+	if err := cp.pollRPCToURL(ctx, be, be.syncStatusURL, &rpcRes, "optimism_syncStatus"); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(rpcRes.Result)
+	if err != nil {
+		return nil, err
+	}
+	return parseSyncStatus(be, data)
+}
+
+func parseSyncStatus(be *Backend, data []byte) (*syncStatus, error) {
+	var status syncStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("unexpected sync status response on backend %s: %w", be.Name, err)
+	}
+	if status.UnsafeL2.Hash == "" {
+		return nil, fmt.Errorf("unsafe head not available on backend %s", be.Name)
+	}
+	return &status, nil
+}
+
+// pollRPC sends a polling request to the backend, within the poller limit of concurrent fetches,
+// and records the latency of the backend
+func (cp *ConsensusPoller) pollRPC(ctx context.Context, be *Backend, res *RPCRes, method string, params ...any) error {
+	return cp.pollRPCToURL(ctx, be, "", res, method, params...)
+}
+
+// pollRPCToURL is like pollRPC, but sends the request to rpcURL when set instead of the rpc URL of the backend
+func (cp *ConsensusPoller) pollRPCToURL(ctx context.Context, be *Backend, rpcURL string, res *RPCRes, method string, params ...any) error {
+	if cp.fetches != nil {
+		if err := cp.fetches.Acquire(ctx, 1); err != nil {
+			return err
+		}
+		defer cp.fetches.Release(1)
+	}
+
+	cp.recordPollRequest(be)
+	start := time.Now()
+	if err := be.forwardRPCToURL(ctx, cp.pollerClient(be), rpcURL, res, "67", method, params...); err != nil {
+		return err
+	}
+	latency := time.Since(start)
+	cp.recordBackendLatency(be, latency)
+	if headLatency, ok := ctx.Value(headLatencyKey{}).(*time.Duration); ok && latency > *headLatency {
+		*headLatency = latency
+	}
+	return nil
+}
+
+// headLatencyKey carries the slowest round trip of the polls of a backend head, checked against its max
+// latency. Unlike the whole fetch, it leaves out the wait for the poller limit of concurrent fetches
+type headLatencyKey struct{}
+
+// recordPollRequest counts a polling request sent to the backend, in the metrics and in the current
+// window of its poll budget
+func (cp *ConsensusPoller) recordPollRequest(be *Backend) {
+	RecordConsensusBackendPollRequest(cp.backendGroup, be)
+	if cp.pollBudget <= 0 {
+		return
+	}
+	now := time.Now()
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	defer bs.backendStateMux.Unlock()
+	if now.Sub(bs.pollWindowStart) >= cp.pollBudgetWindow {
+		bs.pollWindowStart = now
+		bs.pollWindowRequests = 0
+	}
+	bs.pollWindowRequests++
+}
+
+// withinPollBudget returns true if the backend was sent fewer polling requests than the poll budget
+// in the current window. The requests of the consensus resolution count toward the budget, but only
+// the polls of the latest block are skipped over it
+func (cp *ConsensusPoller) withinPollBudget(be *Backend) bool {
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	defer bs.backendStateMux.Unlock()
+	return time.Since(bs.pollWindowStart) >= cp.pollBudgetWindow || bs.pollWindowRequests < cp.pollBudget
+}
+
+// pollerClient returns the HTTP client used to poll the backend, sharing the backend concurrency limit.
+// A unix socket backend is always polled with its own client, which dials the socket
+func (cp *ConsensusPoller) pollerClient(be *Backend) *LimitedHTTPClient {
+	if be.socketPath != "" {
+		return be.client
+	}
+	client := cp.backendState[be].pollClient
+	if client == nil {
+		client = cp.client
+	}
+	if client == nil {
+		return be.client
+	}
+	return &LimitedHTTPClient{
+		Client:      *client,
+		sem:         be.client.sem,
+		backendName: be.Name,
+	}
+}
+
+// forceHTTP2 returns a copy of the client whose transport attempts HTTP/2, which the standard library
+// otherwise skips for a transport with a custom TLS config. Clients with a custom round tripper are kept as is
+func forceHTTP2(client *http.Client) *http.Client {
+	h2 := *client
+	transport, ok := client.Transport.(*http.Transport)
+	if client.Transport == nil {
+		transport, ok = http.DefaultTransport.(*http.Transport)
+	}
+	if !ok {
+		return &h2
+	}
+	transport = transport.Clone()
+	transport.ForceAttemptHTTP2 = true
+	h2.Transport = transport
+	return &h2
+}
+
+func (cp *ConsensusPoller) requestBlock(ctx context.Context, be *Backend, block string, fullTxs bool) (map[string]interface{}, error) {
+	method := "eth_getBlockByNumber"
+	if be.consensusBlockMethod != "" {
+		method = be.consensusBlockMethod
+	}
+	var rpcRes RPCRes
+	if err := cp.pollRPC(ctx, be, &rpcRes, method, be.consensusBlockTag(block), fullTxs); err != nil {
+		return nil, err
+	}
+
+	jsonMap, ok := rpcRes.Result.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected response type checking consensus on backend %s", be.Name)
+	}
+	return jsonMap, nil
+}
+
+// normalizeBlockID is the single place the identifiers fetched from the backends are normalized before being
+// compared. It strips the encoding quirks, i.e. surrounding whitespace or quotes, then applies the block
+// identifier normalizer of the poller
+func (cp *ConsensusPoller) normalizeBlockID(id string) (string, error) {
+	return cp.blockIDNormalizer(trimEncoding(id))
+}
+
+// trimEncoding strips the whitespace and the quotes left around a value, i.e. by a backend encoding
+// a string twice
+func trimEncoding(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = strings.TrimSpace(value[1 : len(value)-1])
+	}
+	return value
+}
+
+// parseQuantity parses a numeric field of a response, i.e. a block number or a timestamp. Besides the
+// canonical hex quantities, it accepts leading zeros, an upper case prefix, decimal strings and JSON numbers
+func parseQuantity(value interface{}) (hexutil.Uint64, error) {
+	switch v := value.(type) {
+	case string:
+		s := strings.ToLower(trimEncoding(v))
+		var n uint64
+		var err error
+		if strings.HasPrefix(s, "0x") {
+			n, err = strconv.ParseUint(s[2:], 16, 64)
+		} else {
+			n, err = strconv.ParseUint(s, 10, 64)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("invalid quantity %q", v)
+		}
+		return hexutil.Uint64(n), nil
+	case float64:
+		if v < 0 || v != float64(uint64(v)) {
+			return 0, fmt.Errorf("invalid quantity %v", v)
+		}
+		return hexutil.Uint64(v), nil
+	default:
+		return 0, fmt.Errorf("unexpected quantity type %T", value)
+	}
+}
+
+// parseBlock parses the block, and normalizes its hash and parent hash
+func (cp *ConsensusPoller) parseBlock(be *Backend, jsonMap map[string]interface{}) (blockNumber hexutil.Uint64, blockHash string, parentHash string, err error) {
+	blockNumber, blockHash, parentHash, err = parseBlock(be, jsonMap)
+	if err != nil {
+		return 0, "", "", err
+	}
+	if blockHash, err = cp.normalizeBlockID(blockHash); err != nil {
+		return 0, "", "", fmt.Errorf("unexpected block on backend %s: %w", be.Name, err)
+	}
+	if parentHash != "" {
+		if parentHash, err = cp.normalizeBlockID(parentHash); err != nil {
+			return 0, "", "", fmt.Errorf("unexpected parent block on backend %s: %w", be.Name, err)
+		}
+	}
+	return blockNumber, blockHash, parentHash, nil
+}
+
+func parseBlock(be *Backend, jsonMap map[string]interface{}) (blockNumber hexutil.Uint64, blockHash string, parentHash string, err error) {
+	// pending or not yet mined blocks may be returned with a null hash
+	blockHash, ok := jsonMap["hash"].(string)
+	if !ok {
+		return 0, "", "", fmt.Errorf("block not available on backend %s", be.Name)
+	}
+	blockNumber, err = parseQuantity(jsonMap["number"])
+	if err != nil {
+		return 0, "", "", fmt.Errorf("unexpected block number on backend %s: %w", be.Name, err)
+	}
+	parentHash, _ = jsonMap["parentHash"].(string)
+
+	return
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (cp *ConsensusPoller) getBackendState(be *Backend) (blockNumber hexutil.Uint64, blockHash string) {
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	blockNumber = bs.latestBlockNumber
+	blockHash = bs.latestBlockHash
+	bs.backendStateMux.Unlock()
+	return
+}
+
+func (cp *ConsensusPoller) recordBackendLatency(be *Backend, latency time.Duration) {
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	if bs.latency == 0 {
+		bs.latency = latency
+	} else {
+		bs.latency = time.Duration(latencyEWMAWeight*float64(latency) + (1-latencyEWMAWeight)*float64(bs.latency))
+	}
+	bs.backendStateMux.Unlock()
+}
+
+func (cp *ConsensusPoller) setBackendState(be *Backend, blockNumber hexutil.Uint64, blockHash string) (changed bool) {
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	defer bs.backendStateMux.Unlock()
+	return cp.setBackendStateLocked(be, bs, blockNumber, blockHash)
+}
+
+// setBackendStateOfURL is like setBackendState, but only records the block when the rpc URL of the backend is
+// still at the given generation. Checked under the state lock, a block fetched from a swapped out URL either
+// lands before the reset of the state, or is discarded
+func (cp *ConsensusPoller) setBackendStateOfURL(be *Backend, generation uint64, blockNumber hexutil.Uint64, blockHash string) (changed bool, current bool) {
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	defer bs.backendStateMux.Unlock()
+	if _, g := be.getRPCURL(); g != generation {
+		return false, false
+	}
+	return cp.setBackendStateLocked(be, bs, blockNumber, blockHash), true
+}
+
+// setBackendStateLocked records the latest block of the backend, it must be called with the backend state lock held
+func (cp *ConsensusPoller) setBackendStateLocked(be *Backend, bs *backendState, blockNumber hexutil.Uint64, blockHash string) (changed bool) {
+	changed = bs.latestBlockHash != blockHash
+	bs.latestBlockNumber = blockNumber
+	bs.latestBlockHash = blockHash
+	bs.lastUpdate = time.Now()
+	if changed || bs.lastChange.IsZero() {
+		bs.lastChange = bs.lastUpdate
+	}
+	bs.recordBlockHistory(BackendBlockEntry{
+		BlockNumber: blockNumber,
+		BlockHash:   blockHash,
+		Time:        bs.lastUpdate,
+	}, cp.backendBlockHistorySize)
+	if bs.unavailable {
+		// the poll of the recovery doesn't count toward the stable cycles
+		bs.unavailable = false
+		bs.stableCycles = 0
+		bs.polled = false
+		bs.warmupCycles = cp.warmupCycles
+		if bs.warmupCycles > 0 {
+			cp.logger.Info("backend is back online, warming up", "name", be.Name, "warmupCycles", bs.warmupCycles)
+		}
+	} else {
+		bs.polled = true
+	}
+	return
+}
+
+// countStableCycles counts a stable cycle for each backend successfully polled since the previous cycle, however
+// many times, in agreement with the consensus committed so far, and promotes the backends done with their
+// warm-up or probation to full voting. The stable cycles of a voting backend dropped from the consensus group,
+// or of a backend at the consensus block number with another hash, are reset. A backend behind the consensus
+// block is not counted. A backend ahead of it is counted, its hash at the consensus block number isn't known
+// without fetching it. Any polled backend is counted before a first consensus
+func (cp *ConsensusPoller) countStableCycles() {
+	cp.consensusGroupMux.Lock()
+	consensusHash := cp.consensusHash
+	inGroup := make(map[*Backend]bool, len(cp.consensusGroup))
+	for _, be := range cp.consensusGroup {
+		inGroup[be] = true
+	}
+	cp.consensusGroupMux.Unlock()
+	consensusBlockNumber := cp.tracker.GetConsensusBlockNumber()
+
+	for _, be := range cp.backendGroup.Backends {
+		bs := cp.backendState[be]
+		bs.backendStateMux.Lock()
+		switch {
+		case consensusHash == "":
+			if bs.polled {
+				bs.stableCycles++
+			}
+		case !bs.excludedFromVoting() && !inGroup[be]:
+			bs.stableCycles = 0
+		case bs.latestBlockNumber == consensusBlockNumber && bs.latestBlockHash != consensusHash:
+			bs.stableCycles = 0
+		case bs.latestBlockNumber < consensusBlockNumber:
+		case bs.polled:
+			bs.stableCycles++
+		}
+		bs.polled = false
+		if bs.stableCycles >= bs.warmupCycles {
+			bs.warmupCycles = 0
+		}
+		if bs.stableCycles >= bs.probationCycles {
+			bs.probationCycles = 0
+		}
+		bs.backendStateMux.Unlock()
+	}
+}
+
+// recordBackendStateAges samples how long ago each backend state was updated
+func (cp *ConsensusPoller) recordBackendStateAges() {
+	now := time.Now()
+	for _, be := range cp.backendGroup.Backends {
+		bs := cp.backendState[be]
+		bs.backendStateMux.Lock()
+		lastUpdate := bs.lastUpdate
+		bs.backendStateMux.Unlock()
+		if lastUpdate.IsZero() {
+			continue
+		}
+		RecordConsensusBackendStateAge(cp.backendGroup, now.Sub(lastUpdate))
+	}
+}
+
+// banFrozenBackends bans the backends whose latest block didn't change for longer than the frozen
+// threshold while a peer advanced past it. Unlike a stale block timestamp, this also catches a backend
+// serving a cached latest block that still looks recent
+func (cp *ConsensusPoller) banFrozenBackends() {
+	type head struct {
+		blockNumber hexutil.Uint64
+		lastChange  time.Time
+		unavailable bool
+	}
+	heads := make([]head, len(cp.backendGroup.Backends))
+	for i, be := range cp.backendGroup.Backends {
+		bs := cp.backendState[be]
+		bs.backendStateMux.Lock()
+		heads[i] = head{bs.latestBlockNumber, bs.lastChange, bs.unavailable}
+		bs.backendStateMux.Unlock()
+	}
+
+	now := time.Now()
+	for i, be := range cp.backendGroup.Backends {
+		h := heads[i]
+		if h.lastChange.IsZero() || h.unavailable || now.Sub(h.lastChange) <= cp.frozenThreshold {
+			continue
+		}
+		if !be.votesInConsensus() || cp.isBanned(be) {
+			continue
+		}
+		for j, peer := range heads {
+			if j != i && peer.blockNumber > h.blockNumber && peer.lastChange.After(h.lastChange) {
+				cp.logger.Warn("backend is frozen while its peers advance, banning", "name", be.Name, "blockNum", h.blockNumber, "lastChange", h.lastChange, "peer", cp.backendGroup.Backends[j].Name, "peerBlockNum", peer.blockNumber)
+				cp.Ban(be, fmt.Sprintf("frozen at block %d while %s advanced to block %d", h.blockNumber, cp.backendGroup.Backends[j].Name, peer.blockNumber))
+				break
+			}
+		}
+	}
+}
+
+// minOutlierSampleSize is the number of backends with a state needed for the outlier detection
+const minOutlierSampleSize = 3
+
+// flagOutlierBackends flags the backends whose latest block number is more than the outlier sensitivity
+// median absolute deviations away from the median of the group, and clears the flag of the others. The
+// flagged backends still count toward the median, so they are cleared once they catch up
+func (cp *ConsensusPoller) flagOutlierBackends() {
+	backends := make([]*Backend, 0, len(cp.backendGroup.Backends))
+	blockNumbers := make([]float64, 0, len(cp.backendGroup.Backends))
+	for _, be := range cp.backendGroup.Backends {
+		if !be.votesInConsensus() || !be.Online() || cp.isBanned(be) {
+			continue
+		}
+		bs := cp.backendState[be]
+		bs.backendStateMux.Lock()
+		blockNumber, unavailable := bs.latestBlockNumber, bs.unavailable
+		bs.backendStateMux.Unlock()
+		if blockNumber == 0 || unavailable {
+			continue
+		}
+		backends = append(backends, be)
+		blockNumbers = append(blockNumbers, float64(blockNumber))
+	}
+	if len(backends) < minOutlierSampleSize {
+		return
+	}
+
+	med := median(blockNumbers)
+	deviations := make([]float64, len(blockNumbers))
+	for i, n := range blockNumbers {
+		deviations[i] = math.Abs(n - med)
+	}
+	mad := math.Max(median(deviations), 1)
+
+	for i, be := range backends {
+		outlier := deviations[i]/mad > cp.outlierSensitivity
+		bs := cp.backendState[be]
+		bs.backendStateMux.Lock()
+		flagged := outlier && !bs.outlier
+		bs.outlier = outlier
+		if outlier {
+			bs.stableCycles = 0
+		}
+		bs.backendStateMux.Unlock()
+		if flagged {
+			cp.logger.Warn("backend block number is an outlier of the group, excluding it", "name", be.Name, "blockNum", uint64(blockNumbers[i]), "median", med, "mad", mad, "sensitivity", cp.outlierSensitivity)
+			RecordConsensusBackendOutlier(cp.backendGroup, be)
+		}
+	}
+}
+
+// median returns the median of the values, leaving them untouched
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// handleFetchError bans or backs off the backend, as decided by the error classifier
+func (cp *ConsensusPoller) handleFetchError(be *Backend, err error) {
+	if cp.errorClassifier == nil {
+		return
+	}
+	switch cp.errorClassifier(be, err) {
+	case FetchErrorBan:
+		if cp.inGracePeriod() {
+			cp.logger.Info("not banning backend during the startup grace period", "name", be.Name, "err", err)
+			return
+		}
+		if cp.IsConsensusPaused() {
+			cp.logger.Info("not banning backend while the consensus is paused", "name", be.Name, "err", err)
+			return
+		}
+		cp.Ban(be, fmt.Sprintf("fetch error: %s", err))
+	case FetchErrorBackoff:
+		bs := cp.backendState[be]
+		bs.backendStateMux.Lock()
+		doublings := bs.backoffCount
+		if doublings > maxErrorBackoffDoublings {
+			doublings = maxErrorBackoffDoublings
+		}
+		backoff := cp.errorBackoff << doublings
+		backoffUntil := time.Now().Add(backoff)
+		bs.backoffUntil = backoffUntil
+		bs.backoffCount++
+		bs.backendStateMux.Unlock()
+		cp.logger.Info("backing off backend", "name", be.Name, "backoff", backoff, "backoffUntil", backoffUntil, "err", err)
+	}
+}
+
+// Ban removes the backend from the consensus for the ban period, recording the reason of the ban
+func (cp *ConsensusPoller) Ban(be *Backend, reason string) {
+	bannedUntil := time.Now().Add(cp.banPeriod)
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	bs.bannedUntil = bannedUntil
+	bs.banReason = reason
+	bs.backendStateMux.Unlock()
+	cp.logger.Warn("backend banned", "name", be.Name, "bannedUntil", bannedUntil, "reason", reason)
+}
+
+// BanInfo describes a backend currently banned from the consensus
+type BanInfo struct {
+	Name        string
+	BannedUntil time.Time
+	Reason      string
+}
+
+// GetBannedBackends returns the currently banned backends, in the backend group order
+func (cp *ConsensusPoller) GetBannedBackends() []BanInfo {
+	now := time.Now()
+	banned := make([]BanInfo, 0)
+	for _, be := range cp.backendGroup.Backends {
+		bs := cp.backendState[be]
+		bs.backendStateMux.Lock()
+		if now.Before(bs.bannedUntil) {
+			banned = append(banned, BanInfo{
+				Name:        be.Name,
+				BannedUntil: bs.bannedUntil,
+				Reason:      bs.banReason,
+			})
+		}
+		bs.backendStateMux.Unlock()
+	}
+	return banned
+}
+
+// allowPoll returns true if the circuit breaker of the backend lets it be polled. Once the open period
+// elapses, the circuit turns half-open and a single probe poll is let through
+func (cp *ConsensusPoller) allowPoll(be *Backend) bool {
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	defer bs.backendStateMux.Unlock()
+	switch bs.circuit {
+	case CircuitOpen:
+		if time.Since(bs.circuitOpenedAt) < cp.circuitOpenPeriod {
+			return false
+		}
+		cp.setCircuitState(be, bs, CircuitHalfOpen)
+		cp.logger.Info("probing backend with an open circuit", "name", be.Name, "failures", bs.consecutiveFailures)
+		return true
+	case CircuitHalfOpen:
+		// a probe is already in flight
+		return false
+	default:
+		return true
+	}
+}
+
+// releaseProbe turns a half-open circuit back to open when its probe poll was discarded, i.e. fetched from a
+// swapped out URL. The open period elapsed already, so the next poll probes the backend again
+func (cp *ConsensusPoller) releaseProbe(be *Backend) {
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	defer bs.backendStateMux.Unlock()
+	if bs.circuit == CircuitHalfOpen {
+		cp.setCircuitState(be, bs, CircuitOpen)
+	}
+}
+
+// isCircuitOpen returns true if the polling of the backend is paused, or only probed, by its circuit breaker
+func (cp *ConsensusPoller) isCircuitOpen(be *Backend) bool {
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	defer bs.backendStateMux.Unlock()
+	return bs.circuit != CircuitClosed
+}
+
+// recordPollFailure opens the circuit of the backend after the failure threshold, or when the probe
+// of a half-open circuit fails
+func (cp *ConsensusPoller) recordPollFailure(be *Backend) {
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	defer bs.backendStateMux.Unlock()
+	bs.consecutiveFailures++
+	if bs.circuit == CircuitHalfOpen || (bs.circuit == CircuitClosed && bs.consecutiveFailures >= cp.circuitFailureThreshold) {
+		bs.circuitOpenedAt = time.Now()
+		cp.setCircuitState(be, bs, CircuitOpen)
+		cp.logger.Warn("opening backend circuit, pausing polling", "name", be.Name, "failures", bs.consecutiveFailures, "openPeriod", cp.circuitOpenPeriod)
+	}
+}
+
+// recordPollSuccess closes the circuit of the backend
+func (cp *ConsensusPoller) recordPollSuccess(be *Backend) {
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	defer bs.backendStateMux.Unlock()
+	bs.consecutiveFailures = 0
+	if bs.circuit != CircuitClosed {
+		cp.setCircuitState(be, bs, CircuitClosed)
+		cp.logger.Info("backend recovered, closing circuit", "name", be.Name)
+	}
+}
+
+// setCircuitState must be called with the backend state lock held
+func (cp *ConsensusPoller) setCircuitState(be *Backend, bs *backendState, state CircuitState) {
+	bs.circuit = state
+	RecordConsensusBackendCircuitState(cp.backendGroup, be, state)
+}
+
+// resetStableCycles restarts the count of stable cycles of a backend that diverged from the group
+func (cp *ConsensusPoller) resetStableCycles(be *Backend) {
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	bs.stableCycles = 0
+	bs.backendStateMux.Unlock()
+}
+
+func (cp *ConsensusPoller) setBackendUnavailable(be *Backend) {
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	bs.unavailable = true
+	bs.stableCycles = 0
+	bs.backendStateMux.Unlock()
+}
+
+// inGracePeriod returns true while the poller is within the startup grace period
+func (cp *ConsensusPoller) inGracePeriod() bool {
+	return time.Since(cp.startedAt) < cp.startupGracePeriod
 }
 
-// GetConsensusBlockNumber returns the agreed block number in a consensus
-func (ct *ConsensusPoller) GetConsensusBlockNumber() hexutil.Uint64 {
-	return ct.tracker.GetConsensusBlockNumber()
+// PauseConsensus freezes the consensus block at its current value and suspends the bans and the consensus
+// broken events until ResumeConsensus, i.e. during a network upgrade. The backends are still polled
+func (cp *ConsensusPoller) PauseConsensus() {
+	cp.pausedMux.Lock()
+	cp.paused = true
+	cp.pausedMux.Unlock()
+	cp.logger.Warn("consensus paused", "group", cp.backendGroup.Name, "consensusBlockNumber", cp.GetConsensusBlockNumber())
 }
 
-func (cp *ConsensusPoller) Shutdown() {
-	cp.asyncHandler.Shutdown()
+// ResumeConsensus resumes the consensus updates after PauseConsensus, from the next cycle. On a pause
+// held for a coordinated rollback, it confirms the rollback, see WithRequireManualRollback
+func (cp *ConsensusPoller) ResumeConsensus() {
+	cp.pausedMux.Lock()
+	cp.paused = false
+	if cp.rollbackPending {
+		cp.rollbackPending = false
+		cp.rollbackConfirmed = true
+	}
+	cp.pausedMux.Unlock()
+	cp.logger.Warn("consensus resumed", "group", cp.backendGroup.Name)
 }
 
-// ConsensusAsyncHandler controls the asynchronous polling mechanism, interval and shutdown
-type ConsensusAsyncHandler interface {
-	Init()
-	Shutdown()
+// IsConsensusPaused returns true between PauseConsensus and ResumeConsensus
+func (cp *ConsensusPoller) IsConsensusPaused() bool {
+	cp.pausedMux.Lock()
+	defer cp.pausedMux.Unlock()
+	return cp.paused
 }
 
-// NoopAsyncHandler allows fine control updating the consensus
-type NoopAsyncHandler struct{}
+// IsRollbackPending returns true while the consensus is paused on a coordinated rollback waiting for
+// ResumeConsensus to confirm it
+func (cp *ConsensusPoller) IsRollbackPending() bool {
+	cp.pausedMux.Lock()
+	defer cp.pausedMux.Unlock()
+	return cp.rollbackPending
+}
 
-func NewNoopAsyncHandler() ConsensusAsyncHandler {
-	log.Warn("using NewNoopAsyncHandler")
-	return &NoopAsyncHandler{}
+// isCoordinatedRollback returns true if every available backend, at least one, is below the consensus block
+func (cp *ConsensusPoller) isCoordinatedRollback(currentConsensusBlockNumber hexutil.Uint64) bool {
+	available := 0
+	for _, be := range cp.backendGroup.Backends {
+		if filtered, _ := cp.isFiltered(be); filtered {
+			continue
+		}
+		available++
+		if blockNumber, _ := cp.getBackendState(be); blockNumber >= currentConsensusBlockNumber {
+			return false
+		}
+	}
+	return available > 0
 }
-func (ah *NoopAsyncHandler) Init()     {}
-func (ah *NoopAsyncHandler) Shutdown() {}
 
-// PollerAsyncHandler asynchronously updates each individual backend and the group consensus
-type PollerAsyncHandler struct {
-	ctx context.Context
-	cp  *ConsensusPoller
+// takeRollbackConfirmation consumes the confirmation of a coordinated rollback, returning false if there is none
+func (cp *ConsensusPoller) takeRollbackConfirmation() bool {
+	cp.pausedMux.Lock()
+	defer cp.pausedMux.Unlock()
+	confirmed := cp.rollbackConfirmed
+	cp.rollbackConfirmed = false
+	return confirmed
 }
 
-func NewPollerAsyncHandler(ctx context.Context, cp *ConsensusPoller) ConsensusAsyncHandler {
-	return &PollerAsyncHandler{
-		ctx: ctx,
-		cp:  cp,
+// holdRollback pauses the consensus on a coordinated rollback and alerts, until an operator confirms it
+func (cp *ConsensusPoller) holdRollback(currentConsensusBlockNumber hexutil.Uint64, proposal *consensusProposal) {
+	cp.pausedMux.Lock()
+	cp.paused = true
+	cp.rollbackPending = true
+	cp.pausedMux.Unlock()
+
+	cp.logger.Error("coordinated rollback below the consensus block, pausing the consensus until confirmed", "group", cp.backendGroup.Name, "currentConsensusBlockNumber", currentConsensusBlockNumber, "proposedBlock", proposal.blockNumber, "proposedBlockHash", proposal.blockHash)
+	RecordGroupConsensusRollbackHeld(cp.backendGroup)
+	if cp.webhookURL != "" {
+		cp.emitEvent(ConsensusEvent{
+			Event:               ConsensusEventRollbackHeld,
+			BlockNumber:         proposal.blockNumber,
+			BlockHash:           proposal.blockHash,
+			PreviousBlockNumber: currentConsensusBlockNumber,
+		})
 	}
 }
-func (ah *PollerAsyncHandler) Init() {
-	// create the individual backend pollers
-	for _, be := range ah.cp.backendGroup.Backends {
-		go func(be *Backend) {
-			for {
-				timer := time.NewTimer(PollerInterval)
-				ah.cp.UpdateBackend(ah.ctx, be)
 
-				select {
-				case <-timer.C:
-				case <-ah.ctx.Done():
-					timer.Stop()
-					return
-				}
-			}
-		}(be)
-	}
+// isBanned returns true if the backend is banned from the consensus
+func (cp *ConsensusPoller) isBanned(be *Backend) bool {
+	bs := cp.backendState[be]
+	defer bs.backendStateMux.Unlock()
+	bs.backendStateMux.Lock()
+	return time.Now().Before(bs.bannedUntil)
+}
 
-	// create the group consensus poller
-	go func() {
-		for {
-			timer := time.NewTimer(PollerInterval)
-			ah.cp.UpdateBackendGroupConsensus(ah.ctx)
+// isExcludedFromVoting returns true if the backend must not vote in the consensus, i.e. it is a non-voting
+// or draining backend, it recently came back online, it recently broke the consensus, it is on a fork,
+// or it reports inconsistent, future-dated or outlying latest blocks
+func (cp *ConsensusPoller) isExcludedFromVoting(be *Backend) bool {
+	if !be.votesInConsensus() {
+		return true
+	}
+	bs := cp.backendState[be]
+	defer bs.backendStateMux.Unlock()
+	bs.backendStateMux.Lock()
+	return bs.excludedFromVoting()
+}
 
-			select {
-			case <-timer.C:
-			case <-ah.ctx.Done():
-				timer.Stop()
-				return
+// checkHeadConsistency flags the backend when its latest block moved back further than the
+// max head regression, or forward further than the max head advance past both its previous latest block
+// and the highest block of the other backends. The previous state is kept, so a backend moved back is
+// flagged until it catches up, and a backend moved forward until the group catches up
+func (cp *ConsensusPoller) checkHeadConsistency(be *Backend, latestBlockNumber hexutil.Uint64) error {
+	var groupHead hexutil.Uint64
+	if cp.maxHeadAdvance > 0 {
+		for _, other := range cp.backendGroup.Backends {
+			if other == be {
+				continue
+			}
+			if blockNumber, _ := cp.getBackendState(other); blockNumber > groupHead {
+				groupHead = blockNumber
 			}
 		}
-	}()
-}
-func (ah *PollerAsyncHandler) Shutdown() {
-	ah.cp.cancelFunc()
+	}
+
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	defer bs.backendStateMux.Unlock()
+	previous := bs.latestBlockNumber
+	var err error
+	switch {
+	case previous == 0:
+	case cp.maxHeadRegression > 0 && uint64(latestBlockNumber)+cp.maxHeadRegression < uint64(previous):
+		err = fmt.Errorf("%w: block %d after block %d", ErrInconsistentHead, latestBlockNumber, previous)
+	// the group head is unknown until another backend was polled
+	case cp.maxHeadAdvance > 0 && groupHead > 0 &&
+		uint64(latestBlockNumber) > uint64(previous)+cp.maxHeadAdvance &&
+		uint64(latestBlockNumber) > uint64(groupHead)+cp.maxHeadAdvance:
+		err = fmt.Errorf("%w: block %d after block %d, ahead of the group head %d",
+			ErrInconsistentHead, latestBlockNumber, previous, groupHead)
+	}
+	if err != nil {
+		bs.inconsistentHead = true
+		bs.stableCycles = 0
+		bs.recordReliability(true, cp.reliabilityHalfLife)
+		return err
+	}
+	bs.inconsistentHead = false
+	return nil
 }
 
-type ConsensusOpt func(cp *ConsensusPoller)
+// checkLatency excludes the backend from voting once it was over its max latency for the slow polls in a row,
+// and includes it back as soon as a poll is under it. The backend keeps being polled meanwhile
+func (cp *ConsensusPoller) checkLatency(be *Backend, latency time.Duration) {
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	if latency > be.consensusMaxLatency {
+		bs.slowPolls++
+	} else {
+		bs.slowPolls = 0
+	}
+	slow := bs.slowPolls >= cp.slowPolls
+	changed := slow != bs.slow
+	bs.slow = slow
+	bs.backendStateMux.Unlock()
 
-func WithTracker(tracker ConsensusTracker) ConsensusOpt {
-	return func(cp *ConsensusPoller) {
-		cp.tracker = tracker
+	if !changed {
+		return
+	}
+	RecordConsensusBackendExcludedForLatency(cp.backendGroup, be, slow)
+	if slow {
+		cp.logger.Warn("backend over its max latency, excluding it from voting", "name", be.Name, "latency", latency, "maxLatency", be.consensusMaxLatency, "polls", cp.slowPolls)
+	} else {
+		cp.logger.Info("backend back under its max latency", "name", be.Name, "latency", latency, "maxLatency", be.consensusMaxLatency)
 	}
 }
 
-func WithAsyncHandler(asyncHandler ConsensusAsyncHandler) ConsensusOpt {
-	return func(cp *ConsensusPoller) {
-		cp.asyncHandler = asyncHandler
+// checkClockSkew flags the backend when the timestamp of its latest block is further than the clock skew
+// tolerance ahead of now. Blocks without a timestamp can't be checked and keep the previous flag
+func (cp *ConsensusPoller) checkClockSkew(be *Backend, timestamp uint64, now time.Time) error {
+	if timestamp == 0 {
+		return nil
+	}
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	defer bs.backendStateMux.Unlock()
+	skew := time.Unix(int64(timestamp), 0).Sub(now)
+	if skew > cp.clockSkewTolerance {
+		bs.clockSkewed = true
+		bs.stableCycles = 0
+		bs.recordReliability(true, cp.reliabilityHalfLife)
+		return fmt.Errorf("%w: block timestamp %d is %s ahead, tolerance %s", ErrClockSkew, timestamp, skew.Round(time.Second), cp.clockSkewTolerance)
 	}
+	bs.clockSkewed = false
+	return nil
 }
 
-func NewConsensusPoller(bg *BackendGroup, opts ...ConsensusOpt) *ConsensusPoller {
-	ctx, cancelFunc := context.WithCancel(context.Background())
-
-	state := make(map[*Backend]*backendState, len(bg.Backends))
-	for _, be := range bg.Backends {
-		state[be] = &backendState{}
+// checkEndpointConsistency fetches the latest block of the backend a second time, every load balancer
+// check interval polls or on every poll while flagged, and flags the backend when it returns another hash
+// at the same height. Fetches landing on different heights are inconclusive and keep the previous flag
+func (cp *ConsensusPoller) checkEndpointConsistency(ctx context.Context, be *Backend, latestBlockNumber hexutil.Uint64, latestBlockHash string) error {
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	bs.pollsSinceEndpointCheck++
+	due := bs.inconsistentEndpoint || bs.pollsSinceEndpointCheck >= cp.loadBalancerCheckInterval
+	if due {
+		bs.pollsSinceEndpointCheck = 0
+	}
+	bs.backendStateMux.Unlock()
+	if !due {
+		return nil
 	}
 
-	cp := &ConsensusPoller{
-		cancelFunc:   cancelFunc,
-		backendGroup: bg,
-		backendState: state,
+	blockNumber, blockHash, err := cp.fetchLatestBlock(ctx, be)
+	if err != nil {
+		cp.logger.Warn("error checking backend endpoint consistency", "name", be.Name, "err", err)
+		return nil
+	}
+	if blockNumber != latestBlockNumber {
+		return nil
 	}
 
-	for _, opt := range opts {
-		opt(cp)
+	bs.backendStateMux.Lock()
+	defer bs.backendStateMux.Unlock()
+	if blockHash != latestBlockHash {
+		bs.inconsistentEndpoint = true
+		bs.stableCycles = 0
+		bs.recordReliability(true, cp.reliabilityHalfLife)
+		return fmt.Errorf("%w: hashes %s and %s at block %d", ErrInconsistentEndpoint, latestBlockHash, blockHash, blockNumber)
 	}
+	bs.inconsistentEndpoint = false
+	return nil
+}
 
-	if cp.tracker == nil {
-		cp.tracker = NewInMemoryConsensusTracker()
+// handleBreakers resets the stable cycles of the backends that disagree with the plurality of the group
+// on the block hash at the consensus block that was broken, and puts them on probation when enabled.
+// It returns true if it found breakers
+func (cp *ConsensusPoller) handleBreakers(ctx context.Context, brokenBlock hexutil.Uint64) bool {
+	// the breakers only matter to the promotions from warm-up and probation
+	if cp.probationCycles == 0 && cp.warmupCycles == 0 {
+		return false
+	}
+	voters := make([]*Backend, 0, len(cp.backendGroup.Backends))
+	for _, be := range cp.backendGroup.Backends {
+		if !be.Online() || cp.isBanned(be) || cp.isExcludedFromVoting(be) {
+			continue
+		}
+		voters = append(voters, be)
 	}
 
-	if cp.asyncHandler == nil {
-		cp.asyncHandler = NewPollerAsyncHandler(ctx, cp)
+	results := make([]blockResult, len(voters))
+	err := cp.runConcurrently(ctx, len(voters), func(i int) {
+		res := &results[i]
+		res.number, res.hash, _, res.err = cp.fetchBlock(ctx, voters[i], brokenBlock.String())
+	})
+	if err != nil {
+		cp.logger.Warn("error looking for consensus breakers", "err", err)
+		return false
 	}
 
-	cp.asyncHandler.Init()
+	clusters := make(map[string][]*Backend)
+	clusterHashes := make([]string, 0)
+	for i, be := range voters {
+		if results[i].err != nil {
+			continue
+		}
+		if _, ok := clusters[results[i].hash]; !ok {
+			clusterHashes = append(clusterHashes, results[i].hash)
+		}
+		clusters[results[i].hash] = append(clusters[results[i].hash], be)
+	}
+	if len(clusterHashes) < 2 {
+		return false
+	}
 
-	return cp
+	pluralityBlockHash := pluralityHash(clusters, clusterHashes)
+	for _, hash := range clusterHashes {
+		if hash == pluralityBlockHash {
+			continue
+		}
+		for _, be := range clusters[hash] {
+			bs := cp.backendState[be]
+			bs.backendStateMux.Lock()
+			bs.stableCycles = 0
+			bs.recordReliability(true, cp.reliabilityHalfLife)
+			if cp.probationCycles > bs.probationCycles {
+				bs.probationCycles = cp.probationCycles
+			}
+			bs.backendStateMux.Unlock()
+			if cp.probationCycles > 0 {
+				cp.logger.Warn("backend broke consensus, putting it on probation", "name", be.Name, "blockNum", brokenBlock, "blockHash", hash, "probationCycles", cp.probationCycles)
+			}
+		}
+	}
+	return true
 }
 
-// UpdateBackend refreshes the consensus state of a single backend
-func (cp *ConsensusPoller) UpdateBackend(ctx context.Context, be *Backend) {
-	bs := cp.backendState[be]
-	if time.Now().Before(bs.bannedUntil) {
-		log.Warn("skipping backend banned", "backend", be.Name, "bannedUntil", bs.bannedUntil)
-		return
+// detectForks clusters the backends by their block hash at the given block number, and excludes
+// the minority cluster when the backends are persistently split in two clusters. The backends whose
+// latest block is the given one are not fetched. It returns the blocks of the backends, for the
+// agreement check to reuse
+func (cp *ConsensusPoller) detectForks(ctx context.Context, blockNumber hexutil.Uint64) map[*Backend]blockResult {
+	candidates := make([]*Backend, 0, len(cp.backendGroup.Backends))
+	for _, be := range cp.backendGroup.Backends {
+		if !be.votesInConsensus() || be.IsRateLimited() || !be.Online() || cp.isBanned(be) || cp.isWarmingUp(be) {
+			continue
+		}
+		candidates = append(candidates, be)
+	}
+	results := make([]blockResult, len(candidates))
+	err := cp.runConcurrently(ctx, len(candidates), func(i int) {
+		be, res := candidates[i], &results[i]
+		if latestBlockNumber, latestBlockHash := cp.getBackendState(be); latestBlockNumber == blockNumber {
+			res.number, res.hash = latestBlockNumber, latestBlockHash
+			return
+		}
+		*res = cp.fetchBlockResult(ctx, be, blockNumber.String())
+	})
+	if err != nil {
+		cp.logger.Warn("error detecting forks", "err", err)
+		return nil
 	}
 
-	if be.IsRateLimited() || !be.Online() {
-		return
+	clusters := make(map[string][]*Backend)
+	clusterHashes := make([]string, 0)
+	fetched := make(map[*Backend]blockResult, len(candidates))
+	for i, be := range candidates {
+		if results[i].err != nil {
+			cp.logger.Warn("error detecting forks", "name", be.Name, "err", results[i].err)
+			continue
+		}
+		blockHash := results[i].hash
+		if _, ok := clusters[blockHash]; !ok {
+			clusterHashes = append(clusterHashes, blockHash)
+		}
+		clusters[blockHash] = append(clusters[blockHash], be)
+		fetched[be] = results[i]
 	}
 
-	// we'll introduce here checks to ban the backend
-	// i.e. node is syncing the chain
+	minority := make(map[*Backend]bool)
+	if len(clusterHashes) == 2 {
+		a, b := clusters[clusterHashes[0]], clusters[clusterHashes[1]]
+		if len(b) < len(a) {
+			a, b = b, a
+		}
+		if len(a) < len(b) {
+			for _, be := range a {
+				minority[be] = true
+			}
+		}
+	}
 
-	// then update backend consensus
+	forkedBackendsNames := make([]string, 0)
+	for be := range fetched {
+		bs := cp.backendState[be]
+		bs.backendStateMux.Lock()
+		if minority[be] {
+			bs.stableCycles = 0
+			bs.recordReliability(true, cp.reliabilityHalfLife)
+			bs.forkCycles++
+			if bs.forkCycles >= cp.forkDetectionCycles && !bs.forked {
+				bs.forked = true
+				forkedBackendsNames = append(forkedBackendsNames, be.Name)
+			}
+		} else {
+			bs.forkCycles = 0
+			bs.forked = false
+		}
+		bs.backendStateMux.Unlock()
+	}
 
-	latestBlockNumber, latestBlockHash, err := cp.fetchBlock(ctx, be, "latest")
-	if err != nil {
-		log.Warn("error updating backend", "name", be.Name, "err", err)
-		return
+	if len(forkedBackendsNames) > 0 {
+		sort.Strings(forkedBackendsNames)
+		cp.logger.Warn("fork detected, excluding minority backends from consensus", "blockNum", blockNumber, "backends", strings.Join(forkedBackendsNames, ", "))
+		RecordGroupConsensusForkDetected(cp.backendGroup)
 	}
+	return fetched
+}
 
-	changed := cp.setBackendState(be, latestBlockNumber, latestBlockHash)
+// isWarmingUp returns true if the backend recently came back online and must not vote in the consensus yet
+func (cp *ConsensusPoller) isWarmingUp(be *Backend) bool {
+	bs := cp.backendState[be]
+	defer bs.backendStateMux.Unlock()
+	bs.backendStateMux.Lock()
+	return bs.warmupCycles > 0
+}
 
-	if changed {
-		RecordBackendLatestBlock(be, latestBlockNumber)
-		log.Info("backend state updated", "name", be.Name, "state", bs)
-	}
+// consensusSnapshot is the serialized form of the whole poller state
+type consensusSnapshot struct {
+	ConsensusBlockNumber hexutil.Uint64                   `json:"consensus_block_number"`
+	ConsensusBlockHash   string                           `json:"consensus_block_hash"`
+	ConsensusGroup       []string                         `json:"consensus_group"`
+	Backends             map[string]*backendStateSnapshot `json:"backends"`
 }
 
-// UpdateBackendGroupConsensus resolves the current group consensus based on the state of the backends
-func (cp *ConsensusPoller) UpdateBackendGroupConsensus(ctx context.Context) {
-	var lowestBlock hexutil.Uint64
-	var lowestBlockHash string
+type backendStateSnapshot struct {
+	LatestBlockNumber hexutil.Uint64 `json:"latest_block_number"`
+	LatestBlockHash   string         `json:"latest_block_hash"`
+	LastUpdate        time.Time      `json:"last_update"`
+	BannedUntil       time.Time      `json:"banned_until"`
+	BanReason         string         `json:"ban_reason,omitempty"`
+}
 
-	currentConsensusBlockNumber := cp.GetConsensusBlockNumber()
+// WriteMetricsTextfile writes the current consensus state of the group to filename, in the Prometheus
+// text format read by the node_exporter textfile collector, for debugging without a scrape pipeline
+func (cp *ConsensusPoller) WriteMetricsTextfile(filename string) error {
+	groupLabels := prometheus.Labels{"backend_group_name": cp.backendGroup.metricsName()}
+	consensusBlock := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   MetricsNamespace,
+		Name:        "group_consensus_latest_block",
+		Help:        "Consensus latest block",
+		ConstLabels: groupLabels,
+	})
+	groupSize := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   MetricsNamespace,
+		Name:        "group_consensus_count",
+		Help:        "Number of backends in the consensus group",
+		ConstLabels: groupLabels,
+	})
+	lag := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   MetricsNamespace,
+		Name:        "group_consensus_lag_blocks",
+		Help:        "Number of blocks the consensus is behind the highest observed block",
+		ConstLabels: groupLabels,
+	})
+	banned := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   MetricsNamespace,
+		Name:        "consensus_backend_banned",
+		Help:        "Whether the backend is banned from the consensus (1) or not (0)",
+		ConstLabels: groupLabels,
+	}, []string{"backend_name"})
 
+	blockNumber := cp.GetConsensusBlockNumber()
+	consensusBlock.Set(float64(blockNumber))
+	groupSize.Set(float64(len(cp.GetConsensusGroup())))
+	if highestBlock, _ := cp.GetHighestBlock(); highestBlock > blockNumber {
+		lag.Set(float64(highestBlock - blockNumber))
+	}
 	for _, be := range cp.backendGroup.Backends {
-		backendLatestBlockNumber, backendLatestBlockHash := cp.getBackendState(be)
-		if lowestBlock == 0 || backendLatestBlockNumber < lowestBlock {
-			lowestBlock = backendLatestBlockNumber
-			lowestBlockHash = backendLatestBlockHash
+		v := float64(0)
+		if cp.isBanned(be) {
+			v = 1
 		}
+		banned.WithLabelValues(be.Name).Set(v)
 	}
 
-	// no block to propose (i.e. initializing consensus)
-	if lowestBlock == 0 {
-		return
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(consensusBlock, groupSize, lag, banned)
+	return prometheus.WriteToTextfile(filename, registry)
+}
+
+// ResetBackend clears the consensus state and the ban of the backend, and removes it from the consensus
+// group until it agrees with it again, i.e. after its rpc URL was swapped
+func (cp *ConsensusPoller) ResetBackend(be *Backend) {
+	bs := cp.backendState[be]
+	bs.backendStateMux.Lock()
+	bs.backendStateValues = backendStateValues{}
+	bs.backendStateMux.Unlock()
+
+	cp.removeFromConsensusGroup(be)
+	RecordConsensusBackendInGroup(cp.backendGroup, be, false)
+	if cp.circuitFailureThreshold > 0 {
+		RecordConsensusBackendCircuitState(cp.backendGroup, be, CircuitClosed)
 	}
+	if be.consensusMaxLatency > 0 {
+		RecordConsensusBackendExcludedForLatency(cp.backendGroup, be, false)
+	}
+	cp.logger.Info("backend consensus state reset", "group", cp.backendGroup.Name, "name", be.Name)
+}
 
-	proposedBlock := lowestBlock
-	proposedBlockHash := lowestBlockHash
-	hasConsensus := false
+// Reset clears the accumulated consensus state: the backend states, including bans and error history,
+// the consensus group, the consensus history and the consensus block number, leaving the poller running. It is meant for tests
+// and for reconfiguring a group at runtime; a cycle in flight may still commit its result afterwards
+func (cp *ConsensusPoller) Reset() {
+	for _, bs := range cp.backendState {
+		bs.backendStateMux.Lock()
+		bs.backendStateValues = backendStateValues{}
+		bs.backendStateMux.Unlock()
+	}
 
-	// check if everybody agrees on the same block hash
-	consensusBackends := make([]*Backend, 0, len(cp.backendGroup.Backends))
-	consensusBackendsNames := make([]string, 0, len(cp.backendGroup.Backends))
-	filteredBackendsNames := make([]string, 0, len(cp.backendGroup.Backends))
+	cp.tracker.SetConsensusBlockNumber(0)
+	cp.tracker.SetConsensusConfirmations(0)
+	RecordGroupConsensusLatestBlock(cp.backendGroup, 0)
+	cp.consensusGroupMux.Lock()
+	cp.consensusGroup = nil
+	cp.confirmedBlockNumber = 0
+	cp.routingGroup = nil
+	cp.routingGroupStreaks = nil
+	cp.consensusHash = ""
+	cp.consensusTimestamp = 0
+	cp.lastReorg = nil
+	cp.committedAt = time.Time{}
+	cp.splitBrain = false
+	cp.health = NoConsensus
+	cp.consensusHistory = nil
+	cp.consensusHistoryStart = 0
+	cp.consensusGroupMux.Unlock()
+	// the observed block time is kept, only the next advance starts a new sample
+	cp.blockTimeMux.Lock()
+	cp.lastAdvanceTime = time.Time{}
+	cp.blockTimeMux.Unlock()
+	if cp.splitBrainDetection {
+		RecordGroupConsensusSplitBrain(cp.backendGroup, false)
+	}
+	RecordGroupConsensusHealth(cp.backendGroup, NoConsensus)
+	for _, be := range cp.backendGroup.Backends {
+		RecordConsensusBackendInGroup(cp.backendGroup, be, false)
+		if cp.circuitFailureThreshold > 0 {
+			RecordConsensusBackendCircuitState(cp.backendGroup, be, CircuitClosed)
+		}
+		if be.consensusMaxLatency > 0 {
+			RecordConsensusBackendExcludedForLatency(cp.backendGroup, be, false)
+		}
+	}
 
-	if lowestBlock > currentConsensusBlockNumber {
-		log.Info("validating consensus on block", lowestBlock)
+	cp.logger.Info("consensus state reset", "group", cp.backendGroup.Name)
+}
+
+// Snapshot serializes the consensus state, the consensus group and the state of each backend to JSON
+func (cp *ConsensusPoller) Snapshot() ([]byte, error) {
+	snapshot := consensusSnapshot{
+		ConsensusBlockNumber: cp.GetConsensusBlockNumber(),
+		Backends:             make(map[string]*backendStateSnapshot, len(cp.backendGroup.Backends)),
 	}
 
-	broken := false
-	for !hasConsensus {
-		allAgreed := true
-		consensusBackends = consensusBackends[:0]
-		filteredBackendsNames = filteredBackendsNames[:0]
-		for _, be := range cp.backendGroup.Backends {
-			if be.IsRateLimited() || !be.Online() || time.Now().Before(cp.backendState[be].bannedUntil) {
-				filteredBackendsNames = append(filteredBackendsNames, be.Name)
-				continue
-			}
+	cp.consensusGroupMux.Lock()
+	snapshot.ConsensusBlockHash = cp.consensusHash
+	snapshot.ConsensusGroup = make([]string, 0, len(cp.consensusGroup))
+	for _, be := range cp.consensusGroup {
+		snapshot.ConsensusGroup = append(snapshot.ConsensusGroup, be.Name)
+	}
+	cp.consensusGroupMux.Unlock()
 
-			actualBlockNumber, actualBlockHash, err := cp.fetchBlock(ctx, be, proposedBlock.String())
-			if err != nil {
-				log.Warn("error updating backend", "name", be.Name, "err", err)
-				continue
-			}
-			if proposedBlockHash == "" {
-				proposedBlockHash = actualBlockHash
-			}
-			blocksDontMatch := (actualBlockNumber != proposedBlock) || (actualBlockHash != proposedBlockHash)
-			if blocksDontMatch {
-				if currentConsensusBlockNumber >= actualBlockNumber {
-					log.Warn("backend broke consensus", "name", be.Name, "blockNum", actualBlockNumber, "proposedBlockNum", proposedBlock, "blockHash", actualBlockHash, "proposedBlockHash", proposedBlockHash)
-					broken = true
-				}
-				allAgreed = false
-				break
-			}
-			consensusBackends = append(consensusBackends, be)
-			consensusBackendsNames = append(consensusBackendsNames, be.Name)
+	for _, be := range cp.backendGroup.Backends {
+		bs := cp.backendState[be]
+		bs.backendStateMux.Lock()
+		snapshot.Backends[be.Name] = &backendStateSnapshot{
+			LatestBlockNumber: bs.latestBlockNumber,
+			LatestBlockHash:   bs.latestBlockHash,
+			LastUpdate:        bs.lastUpdate,
+			BannedUntil:       bs.bannedUntil,
+			BanReason:         bs.banReason,
 		}
-		if allAgreed {
-			hasConsensus = true
-		} else {
-			// walk one block behind and try again
-			proposedBlock -= 1
-			proposedBlockHash = ""
-			log.Info("no consensus, now trying", "block:", proposedBlock)
+		bs.backendStateMux.Unlock()
+	}
+
+	return json.Marshal(snapshot)
+}
+
+// Restore loads a state previously serialized with Snapshot.
+// Backends that are not part of the backend group are ignored.
+func (cp *ConsensusPoller) Restore(data []byte) error {
+	var snapshot consensusSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return wrapErr(err, "error decoding consensus snapshot")
+	}
+
+	backendsByName := make(map[string]*Backend, len(cp.backendGroup.Backends))
+	for _, be := range cp.backendGroup.Backends {
+		backendsByName[be.Name] = be
+	}
+
+	for name, s := range snapshot.Backends {
+		be, ok := backendsByName[name]
+		if !ok {
+			cp.logger.Warn("ignoring unknown backend in consensus snapshot", "name", name)
+			continue
 		}
+		bs := cp.backendState[be]
+		bs.backendStateMux.Lock()
+		bs.latestBlockNumber = s.LatestBlockNumber
+		bs.latestBlockHash = s.LatestBlockHash
+		bs.lastUpdate = s.LastUpdate
+		bs.bannedUntil = s.BannedUntil
+		bs.banReason = s.BanReason
+		bs.backendStateMux.Unlock()
 	}
 
-	if broken {
-		// propagate event to other interested parts, such as cache invalidator
-		log.Info("consensus broken", "currentConsensusBlockNumber", currentConsensusBlockNumber, "proposedBlock", proposedBlock, "proposedBlockHash", proposedBlockHash)
+	group := make([]*Backend, 0, len(snapshot.ConsensusGroup))
+	for _, name := range snapshot.ConsensusGroup {
+		be, ok := backendsByName[name]
+		if !ok {
+			cp.logger.Warn("ignoring unknown consensus group member in consensus snapshot", "name", name)
+			continue
+		}
+		group = append(group, be)
 	}
 
-	cp.tracker.SetConsensusBlockNumber(proposedBlock)
-	RecordGroupConsensusLatestBlock(cp.backendGroup, proposedBlock)
+	cp.tracker.SetConsensusBlockNumber(snapshot.ConsensusBlockNumber)
+	cp.tracker.SetConsensusConfirmations(0)
 	cp.consensusGroupMux.Lock()
-	cp.consensusGroup = consensusBackends
+	cp.consensusGroup = group
+	cp.updateRoutingGroupLocked(group, true)
+	cp.consensusHash = snapshot.ConsensusBlockHash
 	cp.consensusGroupMux.Unlock()
 
-	log.Info("group state", "proposedBlock", proposedBlock, "consensusBackends", strings.Join(consensusBackendsNames, ", "), "filteredBackends", strings.Join(filteredBackendsNames, ", "))
+	return nil
 }
 
-// fetchBlock Convenient wrapper to make a request to get a block directly from the backend
-func (cp *ConsensusPoller) fetchBlock(ctx context.Context, be *Backend, block string) (blockNumber hexutil.Uint64, blockHash string, err error) {
-	var rpcRes RPCRes
-	err = be.ForwardRPC(ctx, &rpcRes, "67", "eth_getBlockByNumber", block, false)
-	if err != nil {
-		return 0, "", err
+// ChainConsensusPollers holds the consensus pollers of a process fronting several chains, keyed by chain id
+// and backend group name. The groups are namespaced by their chain id, so their metrics don't collide, and
+// every poller keeps its own state and tracker, so the chains don't interfere with each other
+type ChainConsensusPollers struct {
+	mtx     sync.Mutex
+	pollers map[string]map[string]*ConsensusPoller
+	// chains maps every polled backend to its chain, a backend can't be shared across chains
+	chains map[*Backend]string
+}
+
+func NewChainConsensusPollers() *ChainConsensusPollers {
+	return &ChainConsensusPollers{
+		pollers: make(map[string]map[string]*ConsensusPoller),
+		chains:  make(map[*Backend]string),
+	}
+}
+
+// Add namespaces the backend group under the chain id, and starts its consensus poller
+func (c *ChainConsensusPollers) Add(chainID string, bg *BackendGroup, opts ...ConsensusOpt) (*ConsensusPoller, error) {
+	if chainID == "" {
+		return nil, fmt.Errorf("empty chain id for backend group %s", bg.Name)
 	}
 
-	jsonMap, ok := rpcRes.Result.(map[string]interface{})
-	if !ok {
-		return 0, "", fmt.Errorf("unexpected response type checking consensus on backend %s", be.Name)
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if _, ok := c.pollers[chainID][bg.Name]; ok {
+		return nil, fmt.Errorf("duplicate backend group %s for chain %s", bg.Name, chainID)
+	}
+	for _, be := range bg.Backends {
+		if chain, ok := c.chains[be]; ok && chain != chainID {
+			return nil, fmt.Errorf("backend %s of group %s for chain %s is already polled for chain %s", be.Name, bg.Name, chainID, chain)
+		}
 	}
-	blockNumber = hexutil.Uint64(hexutil.MustDecodeUint64(jsonMap["number"].(string)))
-	blockHash = jsonMap["hash"].(string)
 
-	return
+	bg.ChainID = chainID
+	opts = append([]ConsensusOpt{WithLogger(log.Root().New("chain", chainID))}, opts...)
+	cp := NewConsensusPoller(bg, opts...)
+	bg.Consensus = cp
+	if c.pollers[chainID] == nil {
+		c.pollers[chainID] = make(map[string]*ConsensusPoller)
+	}
+	c.pollers[chainID][bg.Name] = cp
+	for _, be := range bg.Backends {
+		c.chains[be] = chainID
+	}
+	return cp, nil
 }
 
-func (cp *ConsensusPoller) getBackendState(be *Backend) (blockNumber hexutil.Uint64, blockHash string) {
-	bs := cp.backendState[be]
-	bs.backendStateMux.Lock()
-	blockNumber = bs.latestBlockNumber
-	blockHash = bs.latestBlockHash
-	bs.backendStateMux.Unlock()
-	return
+// Get returns the consensus poller of the backend group of the chain, nil if unknown
+func (c *ChainConsensusPollers) Get(chainID string, group string) *ConsensusPoller {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.pollers[chainID][group]
 }
 
-func (cp *ConsensusPoller) setBackendState(be *Backend, blockNumber hexutil.Uint64, blockHash string) (changed bool) {
-	bs := cp.backendState[be]
-	bs.backendStateMux.Lock()
-	changed = bs.latestBlockHash != blockHash
-	bs.latestBlockNumber = blockNumber
-	bs.latestBlockHash = blockHash
-	bs.lastUpdate = time.Now()
-	bs.backendStateMux.Unlock()
-	return
+// ChainIDs returns the chain ids with a consensus poller, sorted
+func (c *ChainConsensusPollers) ChainIDs() []string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	chainIDs := make([]string, 0, len(c.pollers))
+	for chainID := range c.pollers {
+		chainIDs = append(chainIDs, chainID)
+	}
+	sort.Strings(chainIDs)
+	return chainIDs
+}
+
+// Shutdown stops the consensus pollers of every chain
+func (c *ChainConsensusPollers) Shutdown() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, pollers := range c.pollers {
+		for _, cp := range pollers {
+			cp.Shutdown()
+		}
+	}
 }
@@ -0,0 +1,295 @@
+package proxyd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// PollRecorder captures the requests the consensus poller sends to its backends and the responses they
+// serve, so a production incident, i.e. a reorg, can be replayed in a test with a ReplayFetcher.
+// It is attached to a poller with WithPollRecorder
+type PollRecorder struct {
+	mtx      sync.Mutex
+	maxPolls int
+	polls    []recordedPoll
+}
+
+// recordedPoll is a request polled from a backend and its outcome, either a response or a transport error
+type recordedPoll struct {
+	Backend    string          `json:"backend"`
+	Request    json.RawMessage `json:"request"`
+	StatusCode int             `json:"status_code,omitempty"`
+	Response   json.RawMessage `json:"response,omitempty"`
+	// Body holds a response body that is not JSON, i.e. the error page of a load balancer
+	Body  string `json:"body,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type pollRecording struct {
+	Polls []recordedPoll `json:"polls"`
+}
+
+// NewPollRecorder creates a recorder keeping the last maxPolls polls, or all of them when maxPolls is zero
+func NewPollRecorder(maxPolls int) *PollRecorder {
+	return &PollRecorder{maxPolls: maxPolls}
+}
+
+// Recording serializes the recorded polls to JSON, in the order they completed
+func (r *PollRecorder) Recording() ([]byte, error) {
+	r.mtx.Lock()
+	polls := make([]recordedPoll, len(r.polls))
+	copy(polls, r.polls)
+	r.mtx.Unlock()
+	return json.Marshal(pollRecording{Polls: polls})
+}
+
+func (r *PollRecorder) record(poll recordedPoll) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.polls = append(r.polls, poll)
+	if r.maxPolls > 0 && len(r.polls) > r.maxPolls {
+		r.polls = r.polls[len(r.polls)-r.maxPolls:]
+	}
+}
+
+// wrap returns a copy of the client recording the polls of the backend with the given name
+func (r *PollRecorder) wrap(client *http.Client, backend string) *http.Client {
+	recording := *client
+	recording.Transport = &recordingTransport{
+		recorder: r,
+		backend:  backend,
+		base:     client.Transport,
+	}
+	return &recording
+}
+
+type recordingTransport struct {
+	recorder *PollRecorder
+	backend  string
+	base     http.RoundTripper
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	// the request is cloned before its body is replaced, a round tripper must not modify it
+	sent := req.Clone(req.Context())
+	sent.Body = io.NopCloser(bytes.NewReader(reqBody))
+
+	poll := recordedPoll{
+		Backend: t.backend,
+		Request: reqBody,
+	}
+	res, err := base.RoundTrip(sent)
+	if err != nil {
+		poll.Error = redactURLError(err).Error()
+		t.recorder.record(poll)
+		return nil, err
+	}
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+
+	poll.StatusCode = res.StatusCode
+	if json.Valid(resBody) {
+		poll.Response = resBody
+	} else {
+		poll.Body = string(resBody)
+	}
+	t.recorder.record(poll)
+	return res, nil
+}
+
+// ReplayFetcher serves the polls of a recording back to the consensus poller, attached with WithPollReplay.
+// Each request of a backend is answered with the responses recorded for the same request, in their recorded
+// order, the last one repeating once they are exhausted, so a poller replaying a recording takes the same
+// consensus decisions as the one recording it, whatever the order of its concurrent polls
+type ReplayFetcher struct {
+	mtx    sync.Mutex
+	polls  map[string][]recordedPoll
+	served map[string]int
+}
+
+// NewReplayFetcher creates a replay of the recording serialized by PollRecorder.Recording
+func NewReplayFetcher(recording []byte) (*ReplayFetcher, error) {
+	var rec pollRecording
+	if err := json.Unmarshal(recording, &rec); err != nil {
+		return nil, fmt.Errorf("invalid poll recording: %w", err)
+	}
+	r := &ReplayFetcher{
+		polls:  make(map[string][]recordedPoll),
+		served: make(map[string]int),
+	}
+	for i, poll := range rec.Polls {
+		key, err := replayKey(poll.Backend, poll.Request)
+		if err != nil {
+			return nil, fmt.Errorf("invalid request of recorded poll %d: %w", i, err)
+		}
+		r.polls[key] = append(r.polls[key], poll)
+	}
+	return r, nil
+}
+
+// client returns an HTTP client serving the recorded polls of the backend with the given name
+func (r *ReplayFetcher) client(backend string) *http.Client {
+	return &http.Client{Transport: &replayTransport{replay: r, backend: backend}}
+}
+
+// next returns the next recorded poll answering the request
+func (r *ReplayFetcher) next(key string) (recordedPoll, bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	polls := r.polls[key]
+	if len(polls) == 0 {
+		return recordedPoll{}, false
+	}
+	i := r.served[key]
+	if i < len(polls)-1 {
+		r.served[key]++
+	}
+	return polls[i], true
+}
+
+type replayTransport struct {
+	replay  *ReplayFetcher
+	backend string
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	key, err := replayKey(t.backend, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	poll, ok := t.replay.next(key)
+	if !ok {
+		return nil, fmt.Errorf("no recorded poll of backend %s for %s", t.backend, key)
+	}
+	if poll.Error != "" {
+		return nil, errors.New(poll.Error)
+	}
+
+	resBody := []byte(poll.Body)
+	if poll.Response != nil {
+		resBody = replayResponseIDs(poll.Request, reqBody, poll.Response)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", poll.StatusCode, http.StatusText(poll.StatusCode)),
+		StatusCode:    poll.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(resBody)),
+		ContentLength: int64(len(resBody)),
+		Request:       req,
+	}, nil
+}
+
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	defer req.Body.Close()
+	return io.ReadAll(req.Body)
+}
+
+// parsePolledReqs parses the body of a polling request, a single request or a batch
+func parsePolledReqs(body []byte) ([]*RPCReq, error) {
+	if IsBatch(body) {
+		var reqs []*RPCReq
+		if err := json.Unmarshal(body, &reqs); err != nil {
+			return nil, err
+		}
+		return reqs, nil
+	}
+	var req RPCReq
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	return []*RPCReq{&req}, nil
+}
+
+// replayKey identifies a polling request of a backend by its methods and params, leaving out the request
+// IDs which differ from one run of the poller to the next
+func replayKey(backend string, body []byte) (string, error) {
+	reqs, err := parsePolledReqs(body)
+	if err != nil {
+		return "", err
+	}
+	calls := make([]string, 0, len(reqs))
+	for _, req := range reqs {
+		var params bytes.Buffer
+		if len(req.Params) > 0 {
+			if err := json.Compact(&params, req.Params); err != nil {
+				return "", err
+			}
+		}
+		calls = append(calls, req.Method+params.String())
+	}
+	return backend + " " + strings.Join(calls, ","), nil
+}
+
+// replayResponseIDs rewrites the IDs of a recorded response from those of the recorded request to those of
+// the replayed one, position by position, as the batch responses are matched to their requests by ID.
+// A response that doesn't parse is served as recorded
+func replayResponseIDs(recordedReq []byte, replayedReq []byte, response []byte) []byte {
+	recorded, err := parsePolledReqs(recordedReq)
+	if err != nil {
+		return response
+	}
+	replayed, err := parsePolledReqs(replayedReq)
+	if err != nil || len(replayed) != len(recorded) {
+		return response
+	}
+	ids := make(map[string]json.RawMessage, len(recorded))
+	for i, req := range recorded {
+		ids[string(req.ID)] = replayed[i].ID
+	}
+	rewrite := func(res map[string]json.RawMessage) {
+		if id, ok := ids[string(res["id"])]; ok {
+			res["id"] = id
+		}
+	}
+
+	var rewritten []byte
+	if IsBatch(response) {
+		var batch []map[string]json.RawMessage
+		if err := json.Unmarshal(response, &batch); err != nil {
+			return response
+		}
+		for _, res := range batch {
+			rewrite(res)
+		}
+		rewritten, err = json.Marshal(batch)
+	} else {
+		var res map[string]json.RawMessage
+		if err := json.Unmarshal(response, &res); err != nil {
+			return response
+		}
+		rewrite(res)
+		rewritten, err = json.Marshal(res)
+	}
+	if err != nil {
+		return response
+	}
+	return rewritten
+}
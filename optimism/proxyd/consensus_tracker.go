@@ -15,12 +15,16 @@ import (
 type ConsensusTracker interface {
 	GetConsensusBlockNumber() hexutil.Uint64
 	SetConsensusBlockNumber(blockNumber hexutil.Uint64)
+	// GetConsensusConfirmations returns the number of cycles the consensus block was held since it was reached
+	GetConsensusConfirmations() uint64
+	SetConsensusConfirmations(confirmations uint64)
 }
 
 // InMemoryConsensusTracker store and retrieve in memory, async-safe
 type InMemoryConsensusTracker struct {
-	consensusBlockNumber hexutil.Uint64
-	mutex                sync.Mutex
+	consensusBlockNumber   hexutil.Uint64
+	consensusConfirmations uint64
+	mutex                  sync.Mutex
 }
 
 func NewInMemoryConsensusTracker() ConsensusTracker {
@@ -44,6 +48,20 @@ func (ct *InMemoryConsensusTracker) SetConsensusBlockNumber(blockNumber hexutil.
 	ct.consensusBlockNumber = blockNumber
 }
 
+func (ct *InMemoryConsensusTracker) GetConsensusConfirmations() uint64 {
+	defer ct.mutex.Unlock()
+	ct.mutex.Lock()
+
+	return ct.consensusConfirmations
+}
+
+func (ct *InMemoryConsensusTracker) SetConsensusConfirmations(confirmations uint64) {
+	defer ct.mutex.Unlock()
+	ct.mutex.Lock()
+
+	ct.consensusConfirmations = confirmations
+}
+
 // RedisConsensusTracker uses a Redis `client` to store and retrieve consensus, async-safe
 type RedisConsensusTracker struct {
 	ctx          context.Context
@@ -70,3 +88,17 @@ func (ct *RedisConsensusTracker) GetConsensusBlockNumber() hexutil.Uint64 {
 func (ct *RedisConsensusTracker) SetConsensusBlockNumber(blockNumber hexutil.Uint64) {
 	ct.client.Set(ct.ctx, ct.key(), blockNumber, 0)
 }
+
+func (ct *RedisConsensusTracker) confirmationsKey() string {
+	return fmt.Sprintf("consensus_confirmations:%s", ct.backendGroup)
+}
+
+func (ct *RedisConsensusTracker) GetConsensusConfirmations() uint64 {
+	// a missing key counts as no confirmation
+	confirmations, _ := ct.client.Get(ct.ctx, ct.confirmationsKey()).Uint64()
+	return confirmations
+}
+
+func (ct *RedisConsensusTracker) SetConsensusConfirmations(confirmations uint64) {
+	ct.client.Set(ct.ctx, ct.confirmationsKey(), confirmations, 0)
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/ethereum-optimism/optimism/proxyd"
 	ms "github.com/ethereum-optimism/optimism/proxyd/tools/mockserver/handler"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/stretchr/testify/require"
 )
 
@@ -295,6 +296,303 @@ func TestConsensus(t *testing.T) {
 		// should resolve to 0x1, the highest common ancestor
 		require.Equal(t, "0x1", bg.Consensus.GetConsensusBlockNumber().String())
 	})
+
+	t.Run("null block hash", func(t *testing.T) {
+		h1.ResetOverrides()
+		h2.ResetOverrides()
+
+		for _, be := range bg.Backends {
+			bg.Consensus.UpdateBackend(ctx, be)
+		}
+		bg.Consensus.UpdateBackendGroupConsensus(ctx)
+
+		// all nodes start at block 0x1
+		require.Equal(t, "0x1", bg.Consensus.GetConsensusBlockNumber().String())
+
+		// node1 advances, node2 returns a block without hash
+		h1.AddOverride(&ms.MethodTemplate{
+			Method:   "eth_getBlockByNumber",
+			Block:    "latest",
+			Response: buildResponse("0x2", "hash2"),
+		})
+		h2.AddOverride(&ms.MethodTemplate{
+			Method:   "eth_getBlockByNumber",
+			Block:    "latest",
+			Response: buildNullHashResponse("0x2"),
+		})
+		h2.AddOverride(&ms.MethodTemplate{
+			Method:   "eth_getBlockByNumber",
+			Block:    "0x1",
+			Response: buildNullHashResponse("0x1"),
+		})
+
+		// poll for group consensus, node2 is skipped instead of panicking
+		for _, be := range bg.Backends {
+			bg.Consensus.UpdateBackend(ctx, be)
+		}
+		bg.Consensus.UpdateBackendGroupConsensus(ctx)
+
+		// node2 is still at 0x1, and it is left out of the consensus group
+		require.Equal(t, "0x1", bg.Consensus.GetConsensusBlockNumber().String())
+		consensusGroup := bg.Consensus.GetConsensusGroup()
+		require.Contains(t, consensusGroup, bg.Backends[0])
+		require.NotContains(t, consensusGroup, bg.Backends[1])
+	})
+
+	t.Run("warm up recovered backend", func(t *testing.T) {
+		h1.ResetOverrides()
+		h2.ResetOverrides()
+
+		cp := proxyd.NewConsensusPoller(bg,
+			proxyd.WithAsyncHandler(proxyd.NewNoopAsyncHandler()),
+			proxyd.WithBlockIDNormalizer(proxyd.NormalizeOpaqueBlockID),
+			proxyd.WithWarmupCycles(2))
+		defer cp.Shutdown()
+
+		updateConsensus(ctx, cp, bg)
+		require.Equal(t, "0x1", cp.GetConsensusBlockNumber().String())
+
+		// node2 goes down
+		node2.SetHandler(SingleResponseHandler(503, "unavailable"))
+		updateConsensus(ctx, cp, bg)
+		require.Equal(t, "0x1", cp.GetConsensusBlockNumber().String())
+
+		// node1 advances while node2 comes back lagging behind
+		h1.AddOverride(&ms.MethodTemplate{
+			Method:   "eth_getBlockByNumber",
+			Block:    "latest",
+			Response: buildResponse("0x2", "hash2"),
+		})
+		node2.SetHandler(http.HandlerFunc(h2.Handler))
+
+		// node2 is observed, but it doesn't drag the consensus back while warming up
+		updateConsensus(ctx, cp, bg)
+		require.Equal(t, "0x2", cp.GetConsensusBlockNumber().String())
+		require.NotContains(t, cp.GetConsensusGroup(), bg.Backends[1])
+
+		// node2 catches up
+		h2.AddOverride(&ms.MethodTemplate{
+			Method:   "eth_getBlockByNumber",
+			Block:    "latest",
+			Response: buildResponse("0x2", "hash2"),
+		})
+		updateConsensus(ctx, cp, bg)
+		require.Equal(t, "0x2", cp.GetConsensusBlockNumber().String())
+		require.NotContains(t, cp.GetConsensusGroup(), bg.Backends[1])
+
+		// warm up is complete, node2 is part of the consensus again
+		updateConsensus(ctx, cp, bg)
+		require.Equal(t, "0x2", cp.GetConsensusBlockNumber().String())
+		require.Contains(t, cp.GetConsensusGroup(), bg.Backends[1])
+	})
+
+	t.Run("custom scheduler", func(t *testing.T) {
+		h1.ResetOverrides()
+		h2.ResetOverrides()
+
+		scheduler := &manualScheduler{
+			poller:   bg.Consensus,
+			backends: bg.Backends,
+		}
+		scheduler.Tick(ctx)
+		require.Equal(t, "0x1", bg.Consensus.GetConsensusBlockNumber().String())
+
+		h1.AddOverride(&ms.MethodTemplate{
+			Method:   "eth_getBlockByNumber",
+			Block:    "latest",
+			Response: buildResponse("0x2", "hash2"),
+		})
+		h2.AddOverride(&ms.MethodTemplate{
+			Method:   "eth_getBlockByNumber",
+			Block:    "latest",
+			Response: buildResponse("0x2", "hash2"),
+		})
+
+		scheduler.Tick(ctx)
+		require.Equal(t, "0x2", bg.Consensus.GetConsensusBlockNumber().String())
+		require.Equal(t, 2, scheduler.ticks)
+	})
+
+	t.Run("sampled group state logs", func(t *testing.T) {
+		h1.ResetOverrides()
+		h2.ResetOverrides()
+
+		var groupStateLogs int
+		handler := log.Root().GetHandler()
+		defer log.Root().SetHandler(handler)
+		log.Root().SetHandler(log.FuncHandler(func(r *log.Record) error {
+			if r.Msg == "group state" {
+				groupStateLogs++
+			}
+			return nil
+		}))
+
+		cp := proxyd.NewConsensusPoller(bg,
+			proxyd.WithAsyncHandler(proxyd.NewNoopAsyncHandler()),
+			proxyd.WithBlockIDNormalizer(proxyd.NormalizeOpaqueBlockID),
+			proxyd.WithGroupStateLogInterval(10))
+		defer cp.Shutdown()
+
+		// stable group, logged on the first cycle and then once every 10 cycles
+		for i := 0; i < 25; i++ {
+			updateConsensus(ctx, cp, bg)
+		}
+		require.Equal(t, 3, groupStateLogs)
+
+		// a change in the group state is logged right away
+		h1.AddOverride(&ms.MethodTemplate{
+			Method:   "eth_getBlockByNumber",
+			Block:    "latest",
+			Response: buildResponse("0x2", "hash2"),
+		})
+		h2.AddOverride(&ms.MethodTemplate{
+			Method:   "eth_getBlockByNumber",
+			Block:    "latest",
+			Response: buildResponse("0x2", "hash2"),
+		})
+		updateConsensus(ctx, cp, bg)
+		require.Equal(t, "0x2", cp.GetConsensusBlockNumber().String())
+		require.Equal(t, 4, groupStateLogs)
+	})
+
+	t.Run("membership of the consensus group", func(t *testing.T) {
+		h1.ResetOverrides()
+		h2.ResetOverrides()
+
+		for _, be := range bg.Backends {
+			bg.Consensus.UpdateBackend(ctx, be)
+		}
+		bg.Consensus.UpdateBackendGroupConsensus(ctx)
+
+		require.True(t, bg.Consensus.IsInConsensusGroup("node1"))
+		require.True(t, bg.Consensus.IsInConsensusGroup("node2"))
+		require.False(t, bg.Consensus.IsInConsensusGroup("unknown"))
+
+		// node2 can't serve the consensus block, so it's filtered from the group
+		h2.AddOverride(&ms.MethodTemplate{
+			Method:   "eth_getBlockByNumber",
+			Block:    "0x1",
+			Response: buildNullHashResponse("0x1"),
+		})
+		bg.Consensus.UpdateBackendGroupConsensus(ctx)
+
+		require.True(t, bg.Consensus.IsInConsensusGroup("node1"))
+		require.False(t, bg.Consensus.IsInConsensusGroup("node2"))
+	})
+
+	t.Run("eth_blockNumber capped to the consensus block", func(t *testing.T) {
+		h1.ResetOverrides()
+		h2.ResetOverrides()
+
+		updateConsensus(ctx, bg.Consensus, bg)
+		require.Equal(t, "0x1", bg.Consensus.GetConsensusBlockNumber().String())
+
+		// both backends report a head that is not agreed on yet
+		for _, h := range []*ms.MockedHandler{&h1, &h2} {
+			h.AddOverride(&ms.MethodTemplate{
+				Method:   "eth_blockNumber",
+				Response: `{"jsonrpc": "2.0", "id": 999, "result": "0x3"}`,
+			})
+		}
+
+		client := NewProxydClient("http://127.0.0.1:8080")
+		res, statusCode, err := client.SendRPC("eth_blockNumber", nil)
+		require.NoError(t, err)
+		require.Equal(t, 200, statusCode)
+		RequireEqualJSON(t, []byte(`{"jsonrpc": "2.0", "id": 999, "result": "0x1"}`), res)
+	})
+}
+
+const noConsensusResponse = `{"error":{"code":-32018,"message":"no backends in consensus"},"id":999,"jsonrpc":"2.0"}`
+
+func TestConsensusFailClosed(t *testing.T) {
+	node1 := NewMockBackend(nil)
+	defer node1.Close()
+	node2 := NewMockBackend(nil)
+	defer node2.Close()
+
+	dir, err := os.Getwd()
+	require.NoError(t, err)
+
+	responses := path.Join(dir, "testdata/consensus_responses.yml")
+	chainIdResponse := &ms.MethodTemplate{
+		Method:   "eth_chainId",
+		Response: `{"jsonrpc": "2.0", "id": 999, "result": "0x420"}`,
+	}
+	h1 := ms.MockedHandler{
+		Overrides:    []*ms.MethodTemplate{chainIdResponse},
+		Autoload:     true,
+		AutoloadFile: responses,
+	}
+	h2 := ms.MockedHandler{
+		Overrides:    []*ms.MethodTemplate{chainIdResponse},
+		Autoload:     true,
+		AutoloadFile: responses,
+	}
+
+	require.NoError(t, os.Setenv("NODE1_URL", node1.URL()))
+	require.NoError(t, os.Setenv("NODE2_URL", node2.URL()))
+
+	node1.SetHandler(http.HandlerFunc(h1.Handler))
+	node2.SetHandler(http.HandlerFunc(h2.Handler))
+
+	config := ReadConfig("consensus_fail_closed")
+	client := NewProxydClient("http://127.0.0.1:8545")
+	svr, shutdown, err := proxyd.Start(config)
+	require.NoError(t, err)
+	defer shutdown()
+
+	bg := svr.BackendGroups["node"]
+	require.NotNil(t, bg.Consensus)
+
+	// requests are rejected until the first consensus is reached
+	res, statusCode, err := client.SendRPC("eth_chainId", nil)
+	require.NoError(t, err)
+	require.Equal(t, 503, statusCode)
+	RequireEqualJSON(t, []byte(noConsensusResponse), res)
+	require.Len(t, node1.Requests(), 0)
+	require.Len(t, node2.Requests(), 0)
+
+	updateConsensus(context.Background(), bg.Consensus, bg)
+	require.Len(t, bg.Consensus.GetConsensusGroup(), 2)
+
+	res, statusCode, err = client.SendRPC("eth_chainId", nil)
+	require.NoError(t, err)
+	require.Equal(t, 200, statusCode)
+	RequireEqualJSON(t, []byte(`{"jsonrpc": "2.0", "id": 999, "result": "0x420"}`), res)
+}
+
+// manualScheduler drives a poller only through the public Poller interface
+type manualScheduler struct {
+	poller   proxyd.Poller
+	backends []*proxyd.Backend
+	ticks    int
+}
+
+func (s *manualScheduler) Tick(ctx context.Context) {
+	for _, be := range s.backends {
+		s.poller.UpdateBackend(ctx, be)
+	}
+	s.poller.UpdateBackendGroupConsensus(ctx)
+	s.ticks++
+}
+
+func updateConsensus(ctx context.Context, cp *proxyd.ConsensusPoller, bg *proxyd.BackendGroup) {
+	for _, be := range bg.Backends {
+		cp.UpdateBackend(ctx, be)
+	}
+	cp.UpdateBackendGroupConsensus(ctx)
+}
+
+func buildNullHashResponse(number string) string {
+	return fmt.Sprintf(`{
+      "jsonrpc": "2.0",
+      "id": 67,
+      "result": {
+        "number": "%s",
+		"hash": null
+      }
+    }`, number)
 }
 
 func buildResponse(number string, hash string) string {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 
@@ -253,11 +254,184 @@ var (
 		"backend_group_name",
 	})
 
+	consensusForkDetected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "consensus_fork_detected",
+		Help:      "Count of forks detected, where a minority of backends was excluded from consensus",
+	}, []string{
+		"backend_group_name",
+	})
+
+	consensusSplitBrain = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "group_consensus_split_brain",
+		Help:      "Whether several hash clusters of the group meet the quorum at the same height (1) or not (0)",
+	}, []string{
+		"backend_group_name",
+	})
+
+	consensusHealth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "group_consensus_health",
+		Help:      "Health of the consensus of the group: healthy (0), degraded (1) or no consensus (2)",
+	}, []string{
+		"backend_group_name",
+	})
+
+	consensusRollbackHeld = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "group_consensus_rollback_held_total",
+		Help:      "Count of coordinated rollbacks below the consensus block held for a manual confirmation",
+	}, []string{
+		"backend_group_name",
+	})
+
+	consensusProposalDelta = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "group_consensus_proposal_delta",
+		Help:      "Difference between the block proposed by the last consensus cycle and the consensus block it started from",
+	}, []string{
+		"backend_group_name",
+	})
+
+	consensusNoAgreementAtHead = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "consensus_no_agreement_at_head_total",
+		Help:      "Count of consensus cycles where the proposed block was below the highest observed block",
+	}, []string{
+		"backend_group_name",
+	})
+
+	consensusReferenceDivergence = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "consensus_reference_divergence_total",
+		Help:      "Count of consensus cycles where the consensus diverged from the reference endpoint",
+	}, []string{
+		"backend_group_name",
+	})
+
+	consensusBackendAnchor = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "consensus_backend_anchor_total",
+		Help:      "Count of consensus cycles where the backend defined the lowest block, below the highest block of the group",
+	}, []string{
+		"backend_group_name",
+		"backend_name",
+	})
+
+	consensusShadowBackendLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "consensus_shadow_backend_lag_blocks",
+		Help:      "Number of blocks a shadow backend is behind the consensus, negative when ahead",
+	}, []string{
+		"backend_group_name",
+		"backend_name",
+	})
+
+	consensusShadowBackendDivergence = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "consensus_shadow_backend_divergence_total",
+		Help:      "Count of consensus cycles where a shadow backend had another hash for the consensus block",
+	}, []string{
+		"backend_group_name",
+		"backend_name",
+	})
+
+	consensusInconsistentHead = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "consensus_backend_inconsistent_head_total",
+		Help:      "Count of latest blocks rejected because they regressed from the block previously reported by the backend",
+	}, []string{
+		"backend_group_name",
+		"backend_name",
+	})
+
+	consensusBackendPollRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "consensus_backend_poll_requests_total",
+		Help:      "Count of polling requests sent to the backend by the consensus poller",
+	}, []string{
+		"backend_group_name",
+		"backend_name",
+	})
+
+	consensusBackendPollThrottled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "consensus_backend_poll_throttled_total",
+		Help:      "Count of polls of the backend skipped to stay within its poll budget",
+	}, []string{
+		"backend_group_name",
+		"backend_name",
+	})
+
+	consensusBackendOutlier = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "consensus_backend_outlier_total",
+		Help:      "Count of backends excluded because their latest block number was a statistical outlier of the group",
+	}, []string{
+		"backend_group_name",
+		"backend_name",
+	})
+
+	consensusClockSkew = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "consensus_backend_clock_skew_total",
+		Help:      "Count of latest blocks rejected because they were dated further in the future than the clock skew tolerance",
+	}, []string{
+		"backend_group_name",
+		"backend_name",
+	})
+
+	consensusBackendStateAge = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: MetricsNamespace,
+		Name:      "consensus_backend_state_age_seconds",
+		Help:      "Histogram of the age of the backend states, sampled every consensus cycle",
+		Buckets: []float64{
+			1,
+			2,
+			5,
+			10,
+			30,
+			60,
+			300,
+		},
+	}, []string{
+		"backend_group_name",
+	})
+
+	consensusBackendExcludedForLatency = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "consensus_backend_excluded_for_latency",
+		Help:      "Whether the backend is excluded from voting for being over its max latency (1) or not (0)",
+	}, []string{
+		"backend_group_name",
+		"backend_name",
+	})
+
+	consensusBackendInGroup = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "consensus_backend_in_group",
+		Help:      "Whether the backend is a member of the consensus group (1) or not (0)",
+	}, []string{
+		"backend_group_name",
+		"backend_name",
+	})
+
+	consensusBackendCircuitState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "consensus_backend_circuit_state",
+		Help:      "State of the polling circuit breaker of the backend: closed (0), open (1) or half-open (2)",
+	}, []string{
+		"backend_group_name",
+		"backend_name",
+	})
+
 	backendLatestBlockBackend = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: MetricsNamespace,
 		Name:      "backend_latest_block",
 		Help:      "Current latest block observed per backend",
 	}, []string{
+		"backend_group_name",
 		"backend_name",
 	})
 )
@@ -321,10 +495,98 @@ func RecordBatchSize(size int) {
 	batchSizeHistogram.Observe(float64(size))
 }
 
-func RecordBackendLatestBlock(be *Backend, blockNumber hexutil.Uint64) {
-	backendLatestBlockBackend.WithLabelValues(be.Name).Set(float64(blockNumber))
+func RecordBackendLatestBlock(group *BackendGroup, be *Backend, blockNumber hexutil.Uint64) {
+	backendLatestBlockBackend.WithLabelValues(group.metricsName(), be.Name).Set(float64(blockNumber))
 }
 
 func RecordGroupConsensusLatestBlock(group *BackendGroup, blockNumber hexutil.Uint64) {
-	consensusLatestBlock.WithLabelValues(group.Name).Set(float64(blockNumber))
+	consensusLatestBlock.WithLabelValues(group.metricsName()).Set(float64(blockNumber))
+}
+
+func RecordConsensusBackendStateAge(group *BackendGroup, age time.Duration) {
+	consensusBackendStateAge.WithLabelValues(group.metricsName()).Observe(age.Seconds())
+}
+
+func RecordConsensusBackendInGroup(group *BackendGroup, be *Backend, inGroup bool) {
+	v := float64(0)
+	if inGroup {
+		v = 1
+	}
+	consensusBackendInGroup.WithLabelValues(group.metricsName(), be.Name).Set(v)
+}
+
+func RecordConsensusBackendExcludedForLatency(group *BackendGroup, be *Backend, excluded bool) {
+	v := float64(0)
+	if excluded {
+		v = 1
+	}
+	consensusBackendExcludedForLatency.WithLabelValues(group.metricsName(), be.Name).Set(v)
+}
+
+func RecordConsensusBackendCircuitState(group *BackendGroup, be *Backend, state CircuitState) {
+	consensusBackendCircuitState.WithLabelValues(group.metricsName(), be.Name).Set(float64(state))
+}
+
+func RecordGroupConsensusForkDetected(group *BackendGroup) {
+	consensusForkDetected.WithLabelValues(group.metricsName()).Inc()
+}
+
+func RecordConsensusInconsistentHead(group *BackendGroup, be *Backend) {
+	consensusInconsistentHead.WithLabelValues(group.metricsName(), be.Name).Inc()
+}
+
+func RecordConsensusBackendPollRequest(group *BackendGroup, be *Backend) {
+	consensusBackendPollRequests.WithLabelValues(group.metricsName(), be.Name).Inc()
+}
+
+func RecordConsensusBackendPollThrottled(group *BackendGroup, be *Backend) {
+	consensusBackendPollThrottled.WithLabelValues(group.metricsName(), be.Name).Inc()
+}
+
+func RecordConsensusBackendOutlier(group *BackendGroup, be *Backend) {
+	consensusBackendOutlier.WithLabelValues(group.metricsName(), be.Name).Inc()
+}
+
+func RecordConsensusClockSkew(group *BackendGroup, be *Backend) {
+	consensusClockSkew.WithLabelValues(group.metricsName(), be.Name).Inc()
+}
+
+func RecordGroupConsensusReferenceDivergence(group *BackendGroup) {
+	consensusReferenceDivergence.WithLabelValues(group.metricsName()).Inc()
+}
+
+func RecordConsensusBackendAnchor(group *BackendGroup, be *Backend) {
+	consensusBackendAnchor.WithLabelValues(group.metricsName(), be.Name).Inc()
+}
+
+func RecordConsensusShadowBackendLag(group *BackendGroup, be *Backend, lag int64) {
+	consensusShadowBackendLag.WithLabelValues(group.metricsName(), be.Name).Set(float64(lag))
+}
+
+func RecordConsensusShadowBackendDivergence(group *BackendGroup, be *Backend) {
+	consensusShadowBackendDivergence.WithLabelValues(group.metricsName(), be.Name).Inc()
+}
+
+func RecordGroupConsensusSplitBrain(group *BackendGroup, splitBrain bool) {
+	v := float64(0)
+	if splitBrain {
+		v = 1
+	}
+	consensusSplitBrain.WithLabelValues(group.metricsName()).Set(v)
+}
+
+func RecordGroupConsensusHealth(group *BackendGroup, health ConsensusHealth) {
+	consensusHealth.WithLabelValues(group.metricsName()).Set(float64(health))
+}
+
+func RecordGroupConsensusRollbackHeld(group *BackendGroup) {
+	consensusRollbackHeld.WithLabelValues(group.metricsName()).Inc()
+}
+
+func RecordGroupConsensusProposalDelta(group *BackendGroup, delta int64) {
+	consensusProposalDelta.WithLabelValues(group.metricsName()).Set(float64(delta))
+}
+
+func RecordGroupConsensusNoAgreementAtHead(group *BackendGroup) {
+	consensusNoAgreementAtHead.WithLabelValues(group.metricsName()).Inc()
 }
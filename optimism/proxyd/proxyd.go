@@ -147,11 +147,44 @@ func Start(config *Config) (*Server, func(), error) {
 		if cfg.StripTrailingXFF {
 			opts = append(opts, WithStrippedTrailingXFF())
 		}
+		if cfg.Weight != 0 {
+			opts = append(opts, WithWeight(cfg.Weight))
+		}
+		if cfg.ConsensusVoting != nil {
+			opts = append(opts, WithConsensusVoting(*cfg.ConsensusVoting))
+		}
+		if cfg.ConsensusBlockMethod != "" {
+			opts = append(opts, WithConsensusBlockMethod(cfg.ConsensusBlockMethod))
+		}
+		if cfg.Local {
+			opts = append(opts, WithLocal(true))
+		}
+		if cfg.ConsensusMaxLatency != 0 {
+			opts = append(opts, WithConsensusMaxLatency(time.Duration(cfg.ConsensusMaxLatency)))
+		}
+		if len(cfg.ConsensusTagMapping) > 0 {
+			for tag, mapped := range cfg.ConsensusTagMapping {
+				if !isBlockTag(tag) || !isBlockTag(mapped) {
+					return nil, nil, fmt.Errorf("invalid consensus tag mapping %s to %s for backend %s", tag, mapped, name)
+				}
+			}
+			opts = append(opts, WithConsensusTagMapping(cfg.ConsensusTagMapping))
+		}
+		syncStatusURL, err := ReadFromEnvOrConfig(cfg.SyncStatusURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		if syncStatusURL != "" {
+			if !isHTTPURL(syncStatusURL) {
+				return nil, nil, fmt.Errorf("consensus sync status URL of backend %s must be an HTTP URL", name)
+			}
+			opts = append(opts, WithSyncStatusURL(syncStatusURL))
+		}
 		opts = append(opts, WithProxydIP(os.Getenv("PROXYD_IP")))
 		back := NewBackend(name, rpcURL, wsURL, lim, rpcRequestSemaphore, opts...)
 		backendNames = append(backendNames, name)
 		backendsByName[name] = back
-		log.Info("configured backend", "name", name, "rpc_url", rpcURL, "ws_url", wsURL)
+		log.Info("configured backend", "name", name, "rpc_url", redactURL(rpcURL), "ws_url", redactURL(wsURL))
 	}
 
 	backendGroups := make(map[string]*BackendGroup)
@@ -163,9 +196,52 @@ func Start(config *Config) (*Server, func(), error) {
 			}
 			backends = append(backends, backendsByName[bName])
 		}
+		for _, bName := range bg.ConsensusShadowBackends {
+			if backendsByName[bName] == nil {
+				return nil, nil, fmt.Errorf("shadow backend %s is not defined", bName)
+			}
+			for _, be := range backends {
+				if be.Name == bName {
+					return nil, nil, fmt.Errorf("shadow backend %s is already in backend group %s", bName, bgName)
+				}
+			}
+		}
+		switch ConsensusMode(bg.ConsensusMode) {
+		case "", ConsensusModeLowestBlock, ConsensusModeQuorum, ConsensusModeWeightedMedian, ConsensusModeSingleBackend, ConsensusModeFinalizedQuorum, ConsensusModeHeadOnly:
+		default:
+			return nil, nil, fmt.Errorf("unknown consensus mode %s for backend group %s", bg.ConsensusMode, bgName)
+		}
+		switch RewindStrategy(bg.ConsensusRewindStrategy) {
+		case "", RewindLinear, RewindBatched:
+		default:
+			return nil, nil, fmt.Errorf("unknown consensus rewind strategy %s for backend group %s", bg.ConsensusRewindStrategy, bgName)
+		}
+		switch BlockIDFormat(bg.ConsensusBlockIDFormat) {
+		case "", BlockIDFormatEVM, BlockIDFormatOpaque:
+		default:
+			return nil, nil, fmt.Errorf("unknown consensus block id format %s for backend group %s", bg.ConsensusBlockIDFormat, bgName)
+		}
+		switch FailMode(bg.ConsensusFailMode) {
+		case "", FailOpen, FailClosed:
+		default:
+			return nil, nil, fmt.Errorf("unknown consensus fail mode %s for backend group %s", bg.ConsensusFailMode, bgName)
+		}
+		if bg.ConsensusMinPollInterval != 0 && bg.ConsensusMaxPollInterval != 0 && bg.ConsensusMinPollInterval > bg.ConsensusMaxPollInterval {
+			return nil, nil, fmt.Errorf("consensus_min_poll_interval is above consensus_max_poll_interval for backend group %s", bgName)
+		}
+		if bg.ConsensusFrozenBlockMultiplier != 0 && bg.ConsensusBlockTime == 0 {
+			return nil, nil, fmt.Errorf("consensus_block_time is required with consensus_frozen_block_multiplier for backend group %s", bgName)
+		}
+		if bg.ConsensusPollBudget != 0 && bg.ConsensusPollBudgetWindow == 0 {
+			return nil, nil, fmt.Errorf("consensus_poll_budget_window is required with consensus_poll_budget for backend group %s", bgName)
+		}
+		if bg.ConsensusCircuitBreakerFailures != 0 && bg.ConsensusCircuitBreakerOpenPeriod == 0 {
+			return nil, nil, fmt.Errorf("consensus_circuit_breaker_open_period is required with consensus_circuit_breaker_failures for backend group %s", bgName)
+		}
 		group := &BackendGroup{
 			Name:     bgName,
 			Backends: backends,
+			ChainID:  bg.ChainID,
 		}
 		backendGroups[bgName] = group
 	}
@@ -310,7 +386,169 @@ func Start(config *Config) (*Server, func(), error) {
 			if config.BackendGroups[bgName].ConsensusAsyncHandler == "noop" {
 				copts = append(copts, WithAsyncHandler(NewNoopAsyncHandler()))
 			}
+			if config.BackendGroups[bgName].ConsensusMode != "" {
+				copts = append(copts, WithConsensusMode(ConsensusMode(config.BackendGroups[bgName].ConsensusMode)))
+			}
+			if config.BackendGroups[bgName].ConsensusRewindStrategy != "" {
+				copts = append(copts, WithRewindStrategy(RewindStrategy(config.BackendGroups[bgName].ConsensusRewindStrategy)))
+			}
+			if config.BackendGroups[bgName].ConsensusMaxBlockRange != 0 {
+				copts = append(copts, WithMaxBlockRange(uint64(config.BackendGroups[bgName].ConsensusMaxBlockRange)))
+			}
+			if BlockIDFormat(config.BackendGroups[bgName].ConsensusBlockIDFormat) == BlockIDFormatOpaque {
+				copts = append(copts, WithBlockIDNormalizer(NormalizeOpaqueBlockID))
+			}
+			if config.BackendGroups[bgName].ConsensusFailMode != "" {
+				copts = append(copts, WithFailMode(FailMode(config.BackendGroups[bgName].ConsensusFailMode)))
+			}
+			if config.BackendGroups[bgName].ConsensusReliabilityWeighting {
+				copts = append(copts, WithReliabilityWeighting())
+			}
+			if config.BackendGroups[bgName].ConsensusReliabilityHalfLife != 0 {
+				copts = append(copts, WithReliabilityHalfLife(time.Duration(config.BackendGroups[bgName].ConsensusReliabilityHalfLife)))
+			}
+			if config.BackendGroups[bgName].ConsensusCapBlockNumber {
+				copts = append(copts, WithBlockNumberCap())
+			}
+			if config.BackendGroups[bgName].ConsensusClampLogsRange {
+				copts = append(copts, WithLogsRangeClamp())
+			}
+			if config.BackendGroups[bgName].ConsensusMonotonic {
+				copts = append(copts, WithMonotonicConsensus(true))
+			}
+			if config.BackendGroups[bgName].ConsensusRequireManualRollback {
+				copts = append(copts, WithRequireManualRollback(true))
+			}
+			if config.BackendGroups[bgName].ConsensusQuorum != 0 {
+				copts = append(copts, WithQuorum(config.BackendGroups[bgName].ConsensusQuorum))
+			}
+			if config.BackendGroups[bgName].ConsensusSplitBrainDetection {
+				copts = append(copts, WithSplitBrainDetection())
+			}
+			if config.BackendGroups[bgName].ConsensusWarmupCycles != 0 {
+				copts = append(copts, WithWarmupCycles(config.BackendGroups[bgName].ConsensusWarmupCycles))
+			}
+			if config.BackendGroups[bgName].ConsensusGroupDebounceCycles != 0 {
+				copts = append(copts, WithGroupDebounce(config.BackendGroups[bgName].ConsensusGroupDebounceCycles))
+			}
+			if config.BackendGroups[bgName].ConsensusStartupGracePeriod != 0 {
+				copts = append(copts, WithStartupGracePeriod(time.Duration(config.BackendGroups[bgName].ConsensusStartupGracePeriod)))
+			}
+			if config.BackendGroups[bgName].ConsensusBreakerProbationCycles != 0 {
+				copts = append(copts, WithBreakerProbation(config.BackendGroups[bgName].ConsensusBreakerProbationCycles))
+			}
+			if config.BackendGroups[bgName].ConsensusForkDetectionCycles != 0 {
+				copts = append(copts, WithForkDetection(config.BackendGroups[bgName].ConsensusForkDetectionCycles))
+			}
+			if config.BackendGroups[bgName].ConsensusRateLimitedStateMaxAge != 0 {
+				copts = append(copts, WithRateLimitedStateMaxAge(time.Duration(config.BackendGroups[bgName].ConsensusRateLimitedStateMaxAge)))
+			}
+			if config.BackendGroups[bgName].ConsensusConfirmationDepth != 0 {
+				copts = append(copts, WithConfirmationDepth(uint64(config.BackendGroups[bgName].ConsensusConfirmationDepth)))
+			}
+			if config.BackendGroups[bgName].ConsensusConfirmationCycles != 0 {
+				copts = append(copts, WithConfirmationCycles(uint64(config.BackendGroups[bgName].ConsensusConfirmationCycles)))
+			}
+			if config.BackendGroups[bgName].ConsensusMaxHeadRegression != 0 {
+				copts = append(copts, WithHeadConsistencyCheck(uint64(config.BackendGroups[bgName].ConsensusMaxHeadRegression)))
+			}
+			if config.BackendGroups[bgName].ConsensusMaxHeadAdvance != 0 {
+				copts = append(copts, WithMaxHeadAdvance(uint64(config.BackendGroups[bgName].ConsensusMaxHeadAdvance)))
+			}
+			if config.BackendGroups[bgName].ConsensusClockSkewTolerance != 0 {
+				copts = append(copts, WithClockSkewDetection(time.Duration(config.BackendGroups[bgName].ConsensusClockSkewTolerance)))
+			}
+			if config.BackendGroups[bgName].ConsensusSlowPolls != 0 {
+				copts = append(copts, WithSlowPolls(config.BackendGroups[bgName].ConsensusSlowPolls))
+			}
+			if config.BackendGroups[bgName].ConsensusOutlierSensitivity != 0 {
+				copts = append(copts, WithOutlierDetection(config.BackendGroups[bgName].ConsensusOutlierSensitivity))
+			}
+			if config.BackendGroups[bgName].ConsensusPollBudget != 0 {
+				copts = append(copts, WithPollBudget(config.BackendGroups[bgName].ConsensusPollBudget, time.Duration(config.BackendGroups[bgName].ConsensusPollBudgetWindow)))
+			}
+			if config.BackendGroups[bgName].ConsensusPollHTTP2 {
+				copts = append(copts, WithPollerHTTP2())
+			}
+			if config.BackendGroups[bgName].ConsensusStaleThreshold != 0 {
+				copts = append(copts, WithStaleThreshold(time.Duration(config.BackendGroups[bgName].ConsensusStaleThreshold)))
+			}
+			if config.BackendGroups[bgName].ConsensusLoadBalancerCheckInterval != 0 {
+				copts = append(copts, WithLoadBalancerDetection(config.BackendGroups[bgName].ConsensusLoadBalancerCheckInterval))
+			}
+			if config.BackendGroups[bgName].ConsensusFrozenBlockMultiplier != 0 {
+				copts = append(copts, WithFrozenBackendDetection(time.Duration(config.BackendGroups[bgName].ConsensusBlockTime), config.BackendGroups[bgName].ConsensusFrozenBlockMultiplier))
+			}
+			if config.BackendGroups[bgName].ConsensusCircuitBreakerFailures != 0 {
+				copts = append(copts, WithCircuitBreaker(config.BackendGroups[bgName].ConsensusCircuitBreakerFailures, time.Duration(config.BackendGroups[bgName].ConsensusCircuitBreakerOpenPeriod)))
+			}
+			if config.BackendGroups[bgName].ConsensusSyncStatusHeads {
+				copts = append(copts, WithSyncStatusHeads())
+			}
+			if config.BackendGroups[bgName].ConsensusFinalizedOnly {
+				copts = append(copts, WithFinalizedOnly(true))
+			}
+			if config.BackendGroups[bgName].ConsensusStrictChaining {
+				copts = append(copts, WithStrictChaining())
+			}
+			if config.BackendGroups[bgName].ConsensusWorkerPoolSize != 0 {
+				copts = append(copts, WithWorkerPoolSize(config.BackendGroups[bgName].ConsensusWorkerPoolSize))
+			}
+			if config.BackendGroups[bgName].ConsensusPollSampleSize != 0 {
+				copts = append(copts, WithPollSampleSize(config.BackendGroups[bgName].ConsensusPollSampleSize))
+			}
+			if config.BackendGroups[bgName].ConsensusRefreshDebounce != 0 {
+				copts = append(copts, WithRefreshDebounce(time.Duration(config.BackendGroups[bgName].ConsensusRefreshDebounce)))
+			}
+			if config.BackendGroups[bgName].ConsensusLazyTTL != 0 {
+				copts = append(copts, WithLazyConsensus(time.Duration(config.BackendGroups[bgName].ConsensusLazyTTL)))
+			}
+			if config.BackendGroups[bgName].ConsensusAdaptivePolling {
+				copts = append(copts, WithAdaptivePolling(true))
+				minInterval, maxInterval := time.Duration(config.BackendGroups[bgName].ConsensusMinPollInterval), time.Duration(config.BackendGroups[bgName].ConsensusMaxPollInterval)
+				if minInterval == 0 {
+					minInterval = DefaultMinPollInterval
+				}
+				if maxInterval == 0 {
+					maxInterval = DefaultMaxPollInterval
+				}
+				copts = append(copts, WithPollIntervalBounds(minInterval, maxInterval))
+			}
+			if config.BackendGroups[bgName].ConsensusMaxConcurrentFetches != 0 {
+				copts = append(copts, WithMaxConcurrentFetches(config.BackendGroups[bgName].ConsensusMaxConcurrentFetches))
+			}
+			if config.BackendGroups[bgName].ConsensusHistorySize != 0 {
+				copts = append(copts, WithConsensusHistorySize(config.BackendGroups[bgName].ConsensusHistorySize))
+			}
+			if config.BackendGroups[bgName].ConsensusBackendBlockHistorySize != 0 {
+				copts = append(copts, WithBackendBlockHistorySize(config.BackendGroups[bgName].ConsensusBackendBlockHistorySize))
+			}
+			if config.BackendGroups[bgName].ConsensusReferenceRPCURL != "" {
+				referenceURL, err := ReadFromEnvOrConfig(config.BackendGroups[bgName].ConsensusReferenceRPCURL)
+				if err != nil {
+					return nil, nil, err
+				}
+				copts = append(copts, WithReferenceEndpoint(referenceURL, uint64(config.BackendGroups[bgName].ConsensusReferenceMaxDivergence)))
+			}
+			if config.BackendGroups[bgName].ConsensusWebhookURL != "" {
+				webhookURL, err := ReadFromEnvOrConfig(config.BackendGroups[bgName].ConsensusWebhookURL)
+				if err != nil {
+					return nil, nil, err
+				}
+				copts = append(copts, WithWebhook(webhookURL))
+			}
+			if len(config.BackendGroups[bgName].ConsensusShadowBackends) > 0 {
+				shadowBackends := make([]*Backend, 0, len(config.BackendGroups[bgName].ConsensusShadowBackends))
+				for _, bName := range config.BackendGroups[bgName].ConsensusShadowBackends {
+					shadowBackends = append(shadowBackends, backendsByName[bName])
+				}
+				copts = append(copts, WithShadowBackends(shadowBackends...))
+			}
 			cp := NewConsensusPoller(bg, copts...)
+			if err := cp.ValidateConfig(); err != nil {
+				cp.Shutdown()
+				return nil, nil, err
+			}
 			bg.Consensus = cp
 		}
 	}
@@ -327,6 +565,9 @@ func Start(config *Config) (*Server, func(), error) {
 			gasPriceLVC.Stop()
 		}
 		srv.Shutdown()
+		for _, back := range backendsByName {
+			back.Shutdown()
+		}
 		if err := lim.FlushBackendWSConns(backendNames); err != nil {
 			log.Error("error flushing backend ws conns", "err", err)
 		}
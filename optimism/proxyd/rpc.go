@@ -2,6 +2,7 @@ package proxyd
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"strings"
 )
@@ -134,7 +135,8 @@ func ValidateRPCReq(req *RPCReq) error {
 
 func NewRPCErrorRes(id json.RawMessage, err error) *RPCRes {
 	var rpcErr *RPCErr
-	if rr, ok := err.(*RPCErr); ok {
+	var rr *RPCErr
+	if errors.As(err, &rr) {
 		rpcErr = rr
 	} else {
 		rpcErr = &RPCErr{
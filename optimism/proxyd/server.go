@@ -447,8 +447,10 @@ func (s *Server) handleBatchRPC(ctx context.Context, reqs []json.RawMessage, isL
 	}
 
 	var cached bool
+	_, cacheDisabled := s.cache.(*NoopRPCCache)
 	for group, batch := range batches {
 		var cacheMisses []batchElem
+		var latestMissed bool
 
 		for _, req := range batch {
 			backendRes, _ := s.cache.GetRPC(ctx, req.Req)
@@ -457,6 +459,15 @@ func (s *Server) handleBatchRPC(ctx context.Context, reqs []json.RawMessage, isL
 				cached = true
 			} else {
 				cacheMisses = append(cacheMisses, req)
+				latestMissed = latestMissed || readsLatestBlock(req.Req)
+			}
+		}
+
+		// a cache miss for the latest block refreshes the consensus out of the poller interval, the
+		// refreshes are debounced by the consensus poller
+		if latestMissed && !cacheDisabled {
+			if bg := s.BackendGroups[group.backendGroup]; bg != nil && bg.Consensus != nil {
+				bg.Consensus.TriggerRefresh()
 			}
 		}
 
@@ -657,6 +668,19 @@ func (s *Server) rateLimitSender(ctx context.Context, req *RPCReq) error {
 	return nil
 }
 
+// readsLatestBlock returns true if the request reads the latest block, i.e. eth_blockNumber
+// or eth_getBlockByNumber with a block dependent tag
+func readsLatestBlock(req *RPCReq) bool {
+	switch req.Method {
+	case "eth_blockNumber":
+		return true
+	case "eth_getBlockByNumber":
+		blockNum, _, err := decodeGetBlockByNumberParams(req.Params)
+		return err == nil && isBlockDependentParam(blockNum)
+	}
+	return false
+}
+
 func setCacheHeader(w http.ResponseWriter, cached bool) {
 	if cached {
 		w.Header().Set(cacheStatusHdr, "HIT")